@@ -0,0 +1,575 @@
+// Copyright 2018 ETH Zurich
+// Copyright 2019 ETH Zurich, Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/private/util"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+	"github.com/scionproto/scion/private/tracing"
+	libint "github.com/scionproto/scion/tools/integration"
+	integration "github.com/scionproto/scion/tools/integration/integrationlib"
+
+	"github.com/tzaeschke/scion-hello/config"
+	"github.com/tzaeschke/scion-hello/connect"
+	"github.com/tzaeschke/scion-hello/replypath"
+)
+
+/*
+Usage with dispatcher-less SCION:
+start "tiny" topology
+scion-hello -local 1-ff00:0:112,[::1]:8080 -daemon 127.0.0.12:30255 e2e -mode server
+scion-hello -local 1-ff00:0:110,127.0.0.1:44444 -daemon 127.0.0.12:30255 e2e -mode client -remote 1-ff00:0:112,[::1]:8080
+*/
+
+const (
+	ping = "Hello scion"
+	pong = "Hello scion"
+)
+
+// Ping is the request of the e2e client.
+type Ping struct {
+	Server  addr.IA `json:"server"`
+	Message string  `json:"message"`
+	Trace   []byte  `json:"trace"`
+}
+
+// Pong is the reply of the e2e server.
+type Pong struct {
+	Client  addr.IA `json:"client"`
+	Server  addr.IA `json:"server"`
+	Message string  `json:"message"`
+	Trace   []byte  `json:"trace"`
+}
+
+// Errors of the e2e protocol. They tell apart packets that are not pings or pongs at all
+// from replies of the wrong server or to the wrong client.
+var (
+	errUnexpectedPayload = serrors.New("unexpected payload received")
+	errInvalidPayload    = serrors.New("invalid payload contents")
+	errWrongServer       = serrors.New("unexpected server")
+	errWrongClient       = serrors.New("unexpected client")
+	errWrongMessage      = serrors.New("unexpected message")
+)
+
+// e2eFlags are the flags of the e2e command.
+type e2eFlags struct {
+	mode    string
+	timeout util.DurWrap
+	epic    bool
+	// epicReplies is the policy of the server for requests over EPIC paths.
+	epicReplies replypath.EPICPolicy
+}
+
+var e2e = e2eFlags{timeout: util.DurWrap{Duration: 10 * time.Second}}
+
+func init() {
+	register(&command{
+		name:    "e2e",
+		summary: "Run the end2end integration test client or server",
+		flags: func(fs *flag.FlagSet, cfg *config.Config) {
+			fs.StringVar(&e2e.mode, "mode", integration.ModeClient,
+				"Run in "+integration.ModeClient+" or "+integration.ModeServer+" mode")
+			cfg.RegisterOptionalRemoteFlag(fs)
+			fs.Var(&e2e.timeout, "timeout", "The timeout for each attempt")
+			fs.BoolVar(&e2e.epic, "epic", false, "Enable EPIC.")
+			registerPathFlags(fs)
+			fs.Var(&e2e.epicReplies, "epic-replies", "(Server only) answer pings over EPIC "+
+				"paths with one of "+strings.Join(replypath.EPICPolicies(), ", "))
+			fs.IntVar(&integration.Attempts, "attempts", 1,
+				"Number of attempts before giving up")
+			fs.StringVar(&integration.Progress, "progress", "", "Socket to write progress to")
+		},
+		run: runE2E,
+	})
+}
+
+func runE2E(ctx context.Context, cfg *config.Config) error {
+	if err := validateE2EFlags(cfg); err != nil {
+		return err
+	}
+	integration.Mode = e2e.mode
+
+	closeTracer, err := integration.InitTracer("end2end-" + integration.Mode)
+	if err != nil {
+		return serrors.WrapStr("initializing tracer", err)
+	}
+	defer closeTracer()
+	if integration.Mode == integration.ModeServer {
+		return server{
+			local:      cfg.Local,
+			daemon:     cfg.Daemon,
+			replyPaths: replypath.Reverser{EPIC: e2e.epicReplies},
+		}.run(ctx)
+	}
+	c := client{
+		local:   cfg.Local,
+		daemon:  cfg.Daemon,
+		remote:  cfg.Remote,
+		timeout: e2e.timeout.Duration,
+		epic:    e2e.epic,
+	}
+	return c.run(ctx)
+}
+
+func validateE2EFlags(cfg *config.Config) error {
+	switch e2e.mode {
+	case integration.ModeServer:
+		return nil
+	case integration.ModeClient:
+	default:
+		return serrors.New("unknown mode, must be either '"+integration.ModeClient+
+			"' or '"+integration.ModeServer+"'", "mode", e2e.mode)
+	}
+	// The remote address is validated by the configuration if it is given.
+	if cfg.Remote.Host == nil {
+		return serrors.New("missing remote address")
+	}
+	if e2e.timeout.Duration == 0 {
+		return serrors.New("invalid timeout provided", "timeout", e2e.timeout)
+	}
+	return nil
+}
+
+// sdConn connects to the SCION daemon within the integration I/O timeout.
+func sdConn(ctx context.Context, daemonAddr string) (daemon.Connector, error) {
+	ctx, cancelF := context.WithTimeout(ctx, integration.DefaultIOTimeout)
+	defer cancelF()
+	return connect.Daemon(ctx, daemonAddr)
+}
+
+type server struct {
+	local      snet.UDPAddr
+	daemon     string
+	replyPaths replypath.Reverser
+}
+
+func (s server) run(ctx context.Context) error {
+	log.Info("Starting server", "isd_as", s.local.IA)
+	defer log.Info("Finished server", "isd_as", s.local.IA)
+
+	sdConn, err := sdConn(ctx, s.daemon)
+	if err != nil {
+		return err
+	}
+	defer sdConn.Close()
+	conn, err := connect.NewConnector(sdConn).OpenUDP(s.local.Host)
+	if err != nil {
+		return serrors.WrapStr("listening", err)
+	}
+	defer conn.Close()
+	localAddr := conn.LocalAddr().(*net.UDPAddr)
+	if len(os.Getenv(libint.GoIntegrationEnv)) > 0 {
+		// Needed for integration test ready signal.
+		fmt.Printf("Port=%d\n", localAddr.Port)
+		fmt.Printf("%s%s\n\n", libint.ReadySignal, s.local.IA)
+	}
+	log.Info("Listening", "local", fmt.Sprintf("%v:%d", s.local.Host.IP, localAddr.Port))
+
+	// Unblock the pending read once ctx is done.
+	stop := context.AfterFunc(ctx, func() {
+		if err := conn.SetReadDeadline(time.Now()); err != nil {
+			log.Error("Failed to interrupt read", "err", err)
+		}
+	})
+	defer stop()
+
+	// Receive ping message
+	for {
+		if err := s.handlePing(conn); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			log.Error("Error handling ping", "err", err)
+		}
+	}
+}
+
+func (s server) handlePing(conn snet.PacketConn) error {
+	var p snet.Packet
+	var ov net.UDPAddr
+	if err := readFrom(conn, &p, &ov); err != nil {
+		return serrors.WrapStr("reading packet", err)
+	}
+	udp, ok := p.Payload.(snet.UDPPayload)
+	if !ok {
+		return serrors.WithCtx(errUnexpectedPayload,
+			"source", p.Source,
+			"destination", p.Destination,
+			"type", common.TypeOf(p.Payload),
+		)
+	}
+	var pld Ping
+	if err := json.Unmarshal(udp.Payload, &pld); err != nil {
+		return serrors.WithCtx(errInvalidPayload,
+			"source", p.Source,
+			"destination", p.Destination,
+			"data", string(udp.Payload),
+			"err", err,
+		)
+	}
+
+	// Pings of untraced clients start a new trace.
+	spanCtx, err := opentracing.GlobalTracer().Extract(
+		opentracing.Binary,
+		bytes.NewReader(pld.Trace),
+	)
+	if err != nil && len(pld.Trace) > 0 {
+		log.Debug("Ignoring invalid trace information", "source", p.Source, "err", err)
+	}
+	span, _ := opentracing.StartSpanFromContext(
+		context.Background(),
+		"handle_ping",
+		ext.RPCServerOption(spanCtx),
+	)
+	defer span.Finish()
+	withTag := func(err error) error {
+		tracing.Error(span, err)
+		return err
+	}
+
+	if !pld.Server.Equal(s.local.IA) {
+		return withTag(serrors.WithCtx(errWrongServer,
+			"source", p.Source,
+			"expected", s.local.IA,
+			"actual", pld.Server,
+		))
+	}
+	if pld.Message != ping {
+		return withTag(serrors.WithCtx(errWrongMessage,
+			"source", p.Source,
+			"expected", ping,
+			"actual", pld.Message,
+		))
+	}
+	log.Info(fmt.Sprintf("Ping received from %s:%d, sending pong.", p.Source, udp.SrcPort))
+	raw, err := json.Marshal(Pong{
+		Client:  p.Source.IA,
+		Server:  s.local.IA,
+		Message: pong,
+		Trace:   pld.Trace,
+	})
+	if err != nil {
+		return withTag(serrors.WrapStr("packing pong", err))
+	}
+
+	p.Destination, p.Source = p.Source, p.Destination
+	p.Payload = snet.UDPPayload{
+		DstPort: udp.SrcPort,
+		SrcPort: udp.DstPort,
+		Payload: raw,
+	}
+	replyPath, err := s.replyPaths.Reverse(p.Path)
+	if err != nil {
+		return withTag(serrors.WrapStr("creating reply path", err))
+	}
+	p.Path = replyPath
+	// Send pong
+	sendSpan := opentracing.StartSpan("handle_ping.reply", opentracing.ChildOf(span.Context()))
+	defer sendSpan.Finish()
+	if err := conn.WriteTo(&p, &ov); err != nil {
+		tracing.Error(sendSpan, err)
+		return withTag(serrors.WrapStr("sending reply", err))
+	}
+	log.Debug("pkg sent", "bytes", p.Bytes)
+	log.Info("Sent pong to", "client", p.Destination)
+	return nil
+}
+
+type client struct {
+	local   snet.UDPAddr
+	daemon  string
+	remote  snet.UDPAddr
+	timeout time.Duration
+	epic    bool
+
+	conn   snet.PacketConn
+	sdConn daemon.Connector
+
+	errorPaths map[snet.PathFingerprint]struct{}
+}
+
+func (c *client) run(ctx context.Context) error {
+	pair := fmt.Sprintf("%s -> %s", c.local.IA, c.remote.IA)
+	log.Info("Starting", "pair", pair)
+	defer log.Info("Finished", "pair", pair)
+	defer integration.Done(c.local.IA, c.remote.IA)
+
+	var err error
+	c.sdConn, err = sdConn(ctx, c.daemon)
+	if err != nil {
+		return err
+	}
+	defer c.sdConn.Close()
+	c.conn, err = connect.NewConnector(c.sdConn).OpenUDP(c.local.Host)
+	if err != nil {
+		return serrors.WrapStr("unable to listen", err)
+	}
+	defer c.conn.Close()
+	port := c.conn.LocalAddr().(*net.UDPAddr).Port
+	log.Info("Send on", "local",
+		fmt.Sprintf("%v,[%v]:%d", c.local.IA, c.local.Host.IP, port))
+	c.errorPaths = make(map[snet.PathFingerprint]struct{})
+	if integration.AttemptRepeatedly("End2End", c.attemptRequest) != 0 {
+		return serrors.New("end2end test failed", "pair", pair)
+	}
+	return nil
+}
+
+func (c *client) attemptRequest(n int) bool {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	span, ctx := tracing.CtxWith(timeoutCtx, "attempt")
+	span.SetTag("attempt", n)
+	span.SetTag("src", c.local.IA)
+	span.SetTag("dst", c.remote.IA)
+	defer span.Finish()
+	logger := log.FromCtx(ctx)
+
+	path, err := c.getRemote(ctx, n)
+	if err != nil {
+		logger.Error("Could not get remote", "err", err)
+		return false
+	}
+	span, ctx = tracing.StartSpanFromCtx(ctx, "attempt.ping")
+	defer span.Finish()
+
+	// Send ping
+	if err := c.ping(ctx, n, path); err != nil {
+		tracing.Error(span, err)
+		logger.Error("Could not send packet", "err", err)
+		return false
+	}
+	// Receive pong
+	if err := c.pong(ctx); err != nil {
+		tracing.Error(span, err)
+		logger.Error("Error receiving pong", "err", err)
+		if path != nil {
+			c.errorPaths[snet.Fingerprint(path)] = struct{}{}
+		}
+		return false
+	}
+	return true
+}
+
+func (c *client) ping(ctx context.Context, n int, path snet.Path) error {
+	rawPing, err := json.Marshal(Ping{
+		Server:  c.remote.IA,
+		Message: ping,
+		Trace:   tracing.IDFromCtx(ctx),
+	})
+	if err != nil {
+		return serrors.WrapStr("packing ping", err)
+	}
+	deadline, err := getDeadline(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		return serrors.WrapStr("setting write deadline", err)
+	}
+	if c.remote.NextHop == nil {
+		c.remote.NextHop = &net.UDPAddr{
+			IP:   c.remote.Host.IP,
+			Port: c.remote.Host.Port,
+		}
+	}
+
+	remoteHostIP, ok := netip.AddrFromSlice(c.remote.Host.IP)
+	if !ok {
+		return serrors.New("invalid remote host IP", "ip", c.remote.Host.IP)
+	}
+	localHostIP, ok := netip.AddrFromSlice(c.local.Host.IP)
+	if !ok {
+		return serrors.New("invalid local host IP", "ip", c.local.Host.IP)
+	}
+	pkt := &snet.Packet{
+		PacketInfo: snet.PacketInfo{
+			Destination: snet.SCIONAddress{
+				IA:   c.remote.IA,
+				Host: addr.HostIP(remoteHostIP),
+			},
+			Source: snet.SCIONAddress{
+				IA:   c.local.IA,
+				Host: addr.HostIP(localHostIP),
+			},
+			Path: c.remote.Path,
+			Payload: snet.UDPPayload{
+				SrcPort: uint16(c.conn.LocalAddr().(*net.UDPAddr).Port),
+				DstPort: uint16(c.remote.Host.Port),
+				Payload: rawPing,
+			},
+		},
+	}
+	log.Info("sending ping", "attempt", n, "path", path)
+	if err := c.conn.WriteTo(pkt, c.remote.NextHop); err != nil {
+		return err
+	}
+	log.Debug("pkg sent", "bytes", pkt.Bytes)
+	return nil
+}
+
+func (c *client) getRemote(ctx context.Context, n int) (snet.Path, error) {
+	if c.remote.IA.Equal(c.local.IA) {
+		c.remote.Path = snetpath.Empty{}
+		return nil, nil
+	}
+	span, ctx := tracing.StartSpanFromCtx(ctx, "attempt.get_remote")
+	defer span.Finish()
+	withTag := func(err error) error {
+		tracing.Error(span, err)
+		return err
+	}
+
+	paths, err := c.sdConn.Paths(ctx, c.remote.IA, c.local.IA,
+		daemon.PathReqFlags{Refresh: n != 0})
+	if err != nil {
+		return nil, withTag(serrors.WrapStr("requesting paths", err))
+	}
+	if paths, err = selectPaths(paths); err != nil {
+		return nil, withTag(err)
+	}
+	// If all paths had an error, let's try them again.
+	if len(paths) <= len(c.errorPaths) {
+		c.errorPaths = make(map[snet.PathFingerprint]struct{})
+	}
+	// Select the best path that didn't error before.
+	var path snet.Path
+	for _, p := range paths {
+		if _, ok := c.errorPaths[snet.Fingerprint(p)]; ok {
+			continue
+		}
+		path = p
+		break
+	}
+	if path == nil {
+		return nil, withTag(serrors.New("no path found",
+			"candidates", len(paths),
+			"errors", len(c.errorPaths),
+		))
+	}
+	// Extract forwarding path from the SCION Daemon response.
+	// If the epic flag is set, try to use the EPIC path type header.
+	if c.epic {
+		scionPath, ok := path.Dataplane().(snetpath.SCION)
+		if !ok {
+			return nil, serrors.New("provided path must be of type scion")
+		}
+		epicPath, err := snetpath.NewEPICDataplanePath(scionPath, path.Metadata().EpicAuths)
+		if err != nil {
+			return nil, err
+		}
+		c.remote.Path = epicPath
+	} else {
+		c.remote.Path = path.Dataplane()
+	}
+	c.remote.NextHop = path.UnderlayNextHop()
+	return path, nil
+}
+
+func (c *client) pong(ctx context.Context) error {
+	deadline, err := getDeadline(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return serrors.WrapStr("setting read deadline", err)
+	}
+	var p snet.Packet
+	var ov net.UDPAddr
+	if err := readFrom(c.conn, &p, &ov); err != nil {
+		return serrors.WrapStr("reading packet", err)
+	}
+
+	udp, ok := p.Payload.(snet.UDPPayload)
+	if !ok {
+		return serrors.WithCtx(errUnexpectedPayload,
+			"source", p.Source,
+			"type", common.TypeOf(p.Payload),
+		)
+	}
+	var pld Pong
+	if err := json.Unmarshal(udp.Payload, &pld); err != nil {
+		return serrors.WithCtx(errInvalidPayload,
+			"source", p.Source,
+			"data", string(udp.Payload),
+			"err", err,
+		)
+	}
+	switch {
+	case !pld.Client.Equal(c.local.IA):
+		return serrors.WithCtx(errWrongClient,
+			"expected", c.local.IA,
+			"actual", pld.Client,
+		)
+	case !pld.Server.Equal(c.remote.IA) || !p.Source.IA.Equal(c.remote.IA):
+		return serrors.WithCtx(errWrongServer,
+			"expected", c.remote.IA,
+			"actual", pld.Server,
+			"source", p.Source,
+		)
+	case pld.Message != pong:
+		return serrors.WithCtx(errWrongMessage,
+			"expected", pong,
+			"actual", pld.Message,
+		)
+	}
+	log.Info("Received pong", "server", p.Source)
+	return nil
+}
+
+func getDeadline(ctx context.Context) (time.Time, error) {
+	dl, ok := ctx.Deadline()
+	if !ok {
+		return time.Time{}, serrors.New("no deadline in context")
+	}
+	return dl, nil
+}
+
+func readFrom(conn snet.PacketConn, pkt *snet.Packet, ov *net.UDPAddr) error {
+	err := conn.ReadFrom(pkt, ov)
+	// Attach more context to error
+	var opErr *snet.OpError
+	if !(errors.As(err, &opErr) && opErr.RevInfo() != nil) {
+		return err
+	}
+	return serrors.WithCtx(err,
+		"isd_as", opErr.RevInfo().IA(),
+		"interface", opErr.RevInfo().IfID,
+	)
+}
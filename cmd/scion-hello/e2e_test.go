@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+	integration "github.com/scionproto/scion/tools/integration/integrationlib"
+
+	"github.com/tzaeschke/scion-hello/emunet"
+	"github.com/tzaeschke/scion-hello/replypath"
+)
+
+// TestE2EOverEmulatedNetwork runs the e2e server in 1-ff00:0:112 and the e2e client in
+// 1-ff00:0:111 of the emulated tiny topology. The ping and the pong go through the core AS
+// 1-ff00:0:110.
+func TestE2EOverEmulatedNetwork(t *testing.T) {
+	ia110, ia111, ia112 := mustIA(t, "1-ff00:0:110"), mustIA(t, "1-ff00:0:111"),
+		mustIA(t, "1-ff00:0:112")
+	topo, err := emunet.LoadTopology("../../emunet/testdata/tiny.topo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := emunet.Start(topo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+	integration.Attempts = 1
+
+	ip := net.IP{127, 0, 0, 1}
+	srvAddr := snet.UDPAddr{IA: ia112, Host: &net.UDPAddr{IP: ip, Port: freePort(t, ip)}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- server{
+			local:      srvAddr,
+			daemon:     n.ASes[ia112].DaemonAddr,
+			replyPaths: replypath.Reverser{},
+		}.run(ctx)
+	}()
+	waitListening(t, srvAddr.Host)
+
+	c := &client{
+		local:   snet.UDPAddr{IA: ia111, Host: &net.UDPAddr{IP: ip}},
+		remote:  srvAddr,
+		daemon:  n.ASes[ia111].DaemonAddr,
+		timeout: 5 * time.Second,
+	}
+	// The client only succeeds if it receives the pong of the server in 1-ff00:0:112 to the
+	// client in 1-ff00:0:111.
+	if err := c.run(ctx); err != nil {
+		t.Fatalf("client failed: %v", err)
+	}
+	if got := n.ASes[ia110].Router.Stats().Forwarded; got != 2 {
+		t.Errorf("core router forwarded %d packets, want the ping and the pong", got)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("server.run() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+}
+
+func mustIA(t *testing.T, s string) addr.IA {
+	t.Helper()
+	ia, err := addr.ParseIA(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ia
+}
+
+// freePort returns a UDP port that is currently not in use on ip.
+func freePort(t *testing.T, ip net.IP) int {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// waitListening waits until a socket is bound to a.
+func waitListening(t *testing.T, a *net.UDPAddr) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 5*time.Second; {
+		conn, err := net.ListenUDP("udp", a)
+		if err != nil {
+			return
+		}
+		conn.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("nothing listens on %v", a)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os/signal"
+	"syscall"
+
+	"github.com/scionproto/scion/pkg/private/serrors"
+
+	"github.com/tzaeschke/scion-hello/config"
+	"github.com/tzaeschke/scion-hello/emunet"
+)
+
+var emulateTopo string
+
+func init() {
+	register(&command{
+		name:    "emulate",
+		summary: "Emulate the ASes of a .topo file on the loopback interface",
+		defaults: config.Defaults{
+			// Not used, the emulated network has its own addresses.
+			Local: "1-ff00:0:110,127.0.0.1:0",
+		},
+		flags: func(fs *flag.FlagSet, cfg *config.Config) {
+			fs.StringVar(&emulateTopo, "topo", "", "topology file, e.g. tiny.topo")
+		},
+		run: runEmulate,
+	})
+}
+
+func runEmulate(ctx context.Context, cfg *config.Config) error {
+	if emulateTopo == "" {
+		return serrors.New("missing -topo")
+	}
+	topo, err := emunet.LoadTopology(emulateTopo)
+	if err != nil {
+		return err
+	}
+	n, err := emunet.Start(topo)
+	if err != nil {
+		return err
+	}
+	defer n.Close()
+
+	for _, ia := range topo.IAs() {
+		as := n.ASes[ia]
+		fmt.Printf("%v: daemon %v, router %v\n", ia, as.DaemonAddr, as.RouterAddr)
+	}
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	return nil
+}
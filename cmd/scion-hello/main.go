@@ -0,0 +1,130 @@
+// Command scion-hello bundles the SCION hello tools in a single binary.
+//
+// Usage:
+//
+//	scion-hello [global flags] <command> [command flags]
+//
+// The global flags (-daemon, -local, -gen, -ia, -log.level, -metrics-addr and -config) are
+// shared by all commands. Run `scion-hello <command> -h` for the flags of a command.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/scionproto/scion/pkg/log"
+
+	"github.com/tzaeschke/scion-hello/config"
+	"github.com/tzaeschke/scion-hello/monitor"
+)
+
+// command is a scion-hello subcommand.
+type command struct {
+	// name is the name of the command on the command line.
+	name string
+	// summary is a one line description of the command.
+	summary string
+	// defaults are used for global settings that are not configured.
+	defaults config.Defaults
+	// flags registers the command specific flags. It may be nil.
+	flags func(fs *flag.FlagSet, cfg *config.Config)
+	// run executes the command.
+	run func(ctx context.Context, cfg *config.Config) error
+}
+
+var commands = map[string]*command{}
+
+func register(c *command) {
+	commands[c.name] = c
+}
+
+func main() {
+	os.Exit(realMain())
+}
+
+func realMain() int {
+	var cfg config.Config
+	global := flag.NewFlagSet("scion-hello", flag.ContinueOnError)
+	cfg.RegisterFlags(global)
+	global.Usage = func() { usage(global) }
+	if err := global.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if err := cfg.Load(global, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:", err)
+		return 2
+	}
+	if global.NArg() == 0 {
+		usage(global)
+		return 2
+	}
+	cmd, ok := commands[global.Arg(0)]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Unknown command:", global.Arg(0))
+		usage(global)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("scion-hello "+cmd.name, flag.ContinueOnError)
+	if cmd.flags != nil {
+		cmd.flags(fs, &cfg)
+	}
+	if err := fs.Parse(global.Args()[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if err := cfg.Resolve(cmd.defaults); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:", err)
+		return 2
+	}
+
+	logCfg := log.Config{Console: log.ConsoleConfig{Level: cfg.LogLevel, StacktraceLevel: "none"}}
+	if err := log.Setup(logCfg); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid log level:", err)
+		return 2
+	}
+	defer log.Flush()
+	defer log.HandlePanic()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if cfg.MetricsAddr != "" {
+		go func() {
+			defer log.HandlePanic()
+			if err := monitor.Serve(ctx, cfg.MetricsAddr); err != nil && ctx.Err() == nil {
+				log.Error("Serving metrics failed", "err", err)
+			}
+		}()
+	}
+	if err := cmd.run(ctx, &cfg); err != nil {
+		log.Error(cmd.name+" failed", "err", err)
+		return 1
+	}
+	return 0
+}
+
+func usage(global *flag.FlagSet) {
+	out := global.Output()
+	fmt.Fprintln(out, "Usage: scion-hello [global flags] <command> [command flags]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Global flags:")
+	global.PrintDefaults()
+}
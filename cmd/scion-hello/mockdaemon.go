@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os/signal"
+	"syscall"
+
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/private/serrors"
+
+	"github.com/tzaeschke/scion-hello/config"
+	"github.com/tzaeschke/scion-hello/fakedaemon"
+)
+
+var mockDaemonFixture string
+
+func init() {
+	register(&command{
+		name:    "mock-daemon",
+		summary: "Serve the SCION daemon API on -daemon from a fixture file",
+		defaults: config.Defaults{
+			Daemon: "127.0.0.1:30255",
+			// Only the ISD-AS is used, if the fixture has no local_ia.
+			Local: "1-ff00:0:110,127.0.0.1:0",
+		},
+		flags: func(fs *flag.FlagSet, cfg *config.Config) {
+			fs.StringVar(&mockDaemonFixture, "fixture", "",
+				"JSON or YAML fixture with paths, AS information and scripted behaviors")
+		},
+		run: runMockDaemon,
+	})
+}
+
+func runMockDaemon(ctx context.Context, cfg *config.Config) error {
+	if mockDaemonFixture == "" {
+		return serrors.New("missing -fixture")
+	}
+	f, err := fakedaemon.LoadFixture(mockDaemonFixture)
+	if err != nil {
+		return err
+	}
+	if f.LocalIA.IsZero() {
+		f.LocalIA = cfg.Local.IA
+	}
+	sd, err := fakedaemon.New(f)
+	if err != nil {
+		return err
+	}
+	lis, err := net.Listen("tcp", cfg.Daemon)
+	if err != nil {
+		return serrors.WrapStr("listening", err, "daemon", cfg.Daemon)
+	}
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	fmt.Printf("Mock daemon for %v listening on %v\n", f.LocalIA, lis.Addr())
+
+	srv := &fakedaemon.Server{Connector: sd}
+	err = srv.Serve(ctx, lis)
+	log.Info("Mock daemon stopped", "calls", len(sd.Calls()))
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
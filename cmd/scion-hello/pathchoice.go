@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"github.com/tzaeschke/scion-hello/pathsel"
+)
+
+// pathChoice chooses the path of the client commands by hand instead of taking the best one.
+var pathChoice = struct {
+	interactive bool
+	index       int
+	fingerprint string
+}{index: -1}
+
+// registerPathChoiceFlags registers the flags to choose the path by hand.
+func registerPathChoiceFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&pathChoice.interactive, "interactive", false,
+		"print the candidate paths and prompt for the path to use")
+	fs.IntVar(&pathChoice.index, "path-index", -1,
+		"use the path with this number in the table of -interactive, 0 is the best")
+	fs.StringVar(&pathChoice.fingerprint, "path-fingerprint", "",
+		"use the path whose fingerprint starts with this hex prefix")
+}
+
+// candidate is a row of the path table.
+type candidate struct {
+	path     snet.Path
+	excluded error
+}
+
+// choosePath returns the selected paths with the path chosen by the path choice flags first.
+// decisions are the decisions about all paths of the daemon; the excluded paths are listed,
+// but cannot be chosen. With -interactive, the candidates are printed to out and the choice
+// is read from in. Without path choice flags, it returns selected unchanged.
+func choosePath(selected []snet.Path, decisions []pathsel.Decision, in io.Reader,
+	out io.Writer) ([]snet.Path, error) {
+
+	if !pathChoice.interactive && pathChoice.index < 0 && pathChoice.fingerprint == "" {
+		return selected, nil
+	}
+	// The selected paths keep their rank, so that 0 is the path taken by default.
+	candidates := make([]candidate, 0, len(decisions))
+	for _, p := range selected {
+		candidates = append(candidates, candidate{path: p})
+	}
+	for _, d := range decisions {
+		if d.Excluded != nil {
+			candidates = append(candidates, candidate{path: d.Path, excluded: d.Excluded})
+		}
+	}
+
+	var i int
+	var err error
+	switch {
+	case pathChoice.index >= 0:
+		i = pathChoice.index
+		if i >= len(candidates) {
+			return nil, serrors.New("path index out of range", "index", i,
+				"candidates", len(candidates))
+		}
+	case pathChoice.fingerprint != "":
+		if i, err = findFingerprint(candidates, pathChoice.fingerprint); err != nil {
+			return nil, err
+		}
+	default:
+		printCandidates(out, candidates, time.Now())
+		if i, err = promptIndex(in, out, len(candidates)); err != nil {
+			return nil, err
+		}
+	}
+	if err := candidates[i].excluded; err != nil {
+		return nil, serrors.WrapStr("chosen path is excluded", err, "index", i)
+	}
+
+	// Only the selected paths can be chosen, and they come first.
+	paths := append(make([]snet.Path, 0, len(selected)), selected[i])
+	paths = append(paths, selected[:i]...)
+	return append(paths, selected[i+1:]...), nil
+}
+
+// openTerminal opens the terminal for the prompt of -interactive, so that it works even if
+// stdin or stdout are redirected.
+func openTerminal() (*os.File, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, serrors.WrapStr("-interactive needs a terminal, "+
+			"use -path-index or -path-fingerprint instead", err)
+	}
+	return tty, nil
+}
+
+// findFingerprint returns the index of the only candidate whose fingerprint starts with
+// prefix.
+func findFingerprint(candidates []candidate, prefix string) (int, error) {
+	prefix = strings.ToLower(prefix)
+	found := -1
+	for i, c := range candidates {
+		if !strings.HasPrefix(snet.Fingerprint(c.path).String(), prefix) {
+			continue
+		}
+		if found >= 0 {
+			return 0, serrors.New("ambiguous path fingerprint", "fingerprint", prefix,
+				"first", found, "second", i)
+		}
+		found = i
+	}
+	if found < 0 {
+		return 0, serrors.New("no path with fingerprint", "fingerprint", prefix,
+			"candidates", len(candidates))
+	}
+	return found, nil
+}
+
+// printCandidates prints the table of candidate paths.
+func printCandidates(w io.Writer, candidates []candidate, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tFINGERPRINT\tMTU\tLATENCY\tEXPIRY\tSTATUS\tHOPS")
+	for i, c := range candidates {
+		meta := c.path.Metadata()
+		if meta == nil {
+			meta = &snet.PathMetadata{}
+		}
+		status := "ok"
+		switch {
+		case c.excluded != nil:
+			status = "excluded: " + c.excluded.Error()
+		case !meta.Expiry.IsZero() && !now.Before(meta.Expiry):
+			status = "expired"
+		}
+		fmt.Fprintf(tw, "%d\t%.16s\t%d\t%s\t%s\t%s\t%s\n", i, snet.Fingerprint(c.path),
+			meta.MTU, formatLatency(meta), formatExpiry(meta.Expiry, now), status,
+			formatHops(meta))
+	}
+	tw.Flush()
+}
+
+// promptIndex asks for the number of a candidate until it gets a valid one. An empty answer
+// chooses 0.
+func promptIndex(r io.Reader, w io.Writer, n int) (int, error) {
+	lines := bufio.NewScanner(r)
+	for {
+		fmt.Fprintf(w, "Choose path [0-%d, default 0]: ", n-1)
+		if !lines.Scan() {
+			if err := lines.Err(); err != nil {
+				return 0, serrors.WrapStr("reading path choice", err)
+			}
+			return 0, serrors.New("no path chosen")
+		}
+		answer := strings.TrimSpace(lines.Text())
+		if answer == "" {
+			return 0, nil
+		}
+		if i, err := strconv.Atoi(answer); err == nil && i >= 0 && i < n {
+			return i, nil
+		}
+		fmt.Fprintf(w, "Invalid choice %q.\n", answer)
+	}
+}
+
+// formatLatency returns the sum of the announced latencies, prefixed with ">" if some are
+// unknown, or "?" if none is known.
+func formatLatency(meta *snet.PathMetadata) string {
+	total, ok := pathsel.TotalLatency(meta)
+	if !ok {
+		return "?"
+	}
+	for _, l := range meta.Latency {
+		if l < 0 {
+			return ">" + total.String()
+		}
+	}
+	return total.String()
+}
+
+// formatExpiry returns the time until expiry, rounded to seconds.
+func formatExpiry(expiry, now time.Time) string {
+	if expiry.IsZero() {
+		return "?"
+	}
+	return expiry.Sub(now).Round(time.Second).String()
+}
+
+// formatHops returns the hops of a path in the notation of scion showpaths, e.g.
+// "1-ff00:0:110 1>41 1-ff00:0:111 42>1 2-ff00:0:220".
+func formatHops(meta *snet.PathMetadata) string {
+	intfs := meta.Interfaces
+	if len(intfs) == 0 {
+		return "-"
+	}
+	var b strings.Builder
+	b.WriteString(intfs[0].IA.String())
+	for i := 0; i+1 < len(intfs); i += 2 {
+		fmt.Fprintf(&b, " %d>%d %s", intfs[i].ID, intfs[i+1].ID, intfs[i+1].IA)
+	}
+	return b.String()
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+
+	"github.com/tzaeschke/scion-hello/pathsel"
+)
+
+// choicePaths returns n paths with distinct fingerprints. Their id is the port of the next
+// hop.
+func choicePaths(t *testing.T, n int) []snet.Path {
+	t.Helper()
+	src, err := addr.ParseIA("1-ff00:0:110")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]snet.Path, 0, n)
+	for i := 0; i < n; i++ {
+		paths = append(paths, snetpath.Path{
+			NextHop: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: i},
+			Meta: snet.PathMetadata{Interfaces: []snet.PathInterface{
+				{IA: src, ID: common.IFIDType(i + 1)}, {IA: src, ID: 100},
+			}},
+		})
+	}
+	return paths
+}
+
+func choiceIDs(paths []snet.Path) []int {
+	res := make([]int, 0, len(paths))
+	for _, p := range paths {
+		res = append(res, p.UnderlayNextHop().Port)
+	}
+	return res
+}
+
+func TestChoosePath(t *testing.T) {
+	// Of 17 fingerprints, at least two start with the same hex digit.
+	paths := choicePaths(t, 17)
+	fingerprints := make([]string, len(paths))
+	var ambiguous string
+	firsts := make(map[byte]bool)
+	for i, p := range paths {
+		fingerprints[i] = snet.Fingerprint(p).String()
+		if firsts[fingerprints[i][0]] {
+			ambiguous = fingerprints[i][:1]
+		}
+		firsts[fingerprints[i][0]] = true
+	}
+	// The last path is excluded, the others are selected in the order of paths.
+	selected := paths[:16]
+	decisions := make([]pathsel.Decision, 0, len(paths))
+	for _, p := range selected {
+		decisions = append(decisions, pathsel.Decision{Path: p})
+	}
+	excluded := pathsel.Decision{Path: paths[16], Excluded: pathsel.ErrExcluded}
+	decisions = append(decisions, excluded)
+
+	tests := map[string]struct {
+		interactive bool
+		index       int
+		fingerprint string
+		input       string
+		// first is the id of the chosen path, -1 if choosing fails.
+		first int
+	}{
+		"no choice":          {index: -1, first: 0},
+		"index":              {index: 3, first: 3},
+		"last index":         {index: 15, first: 15},
+		"excluded index":     {index: 16, first: -1},
+		"index out of range": {index: 17, first: -1},
+		"fingerprint":        {index: -1, fingerprint: fingerprints[5], first: 5},
+		"fingerprint prefix": {index: -1, fingerprint: fingerprints[5][:12], first: 5},
+		"upper case fingerprint": {
+			index:       -1,
+			fingerprint: strings.ToUpper(fingerprints[5]),
+			first:       5,
+		},
+		"ambiguous fingerprint": {index: -1, fingerprint: ambiguous, first: -1},
+		"unknown fingerprint":   {index: -1, fingerprint: "xyz", first: -1},
+		"excluded fingerprint":  {index: -1, fingerprint: fingerprints[16], first: -1},
+		"index before prompt": {
+			interactive: true,
+			index:       2,
+			first:       2,
+		},
+		"prompt":          {interactive: true, index: -1, input: "4\n", first: 4},
+		"prompt default":  {interactive: true, index: -1, input: "\n", first: 0},
+		"prompt excluded": {interactive: true, index: -1, input: "16\n", first: -1},
+		"prompt again": {
+			interactive: true,
+			index:       -1,
+			input:       "17\n-1\nfour\n 7 \n",
+			first:       7,
+		},
+		"prompt without answer": {interactive: true, index: -1, input: "99\n", first: -1},
+	}
+	saved := pathChoice
+	defer func() { pathChoice = saved }()
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pathChoice.interactive = tc.interactive
+			pathChoice.index = tc.index
+			pathChoice.fingerprint = tc.fingerprint
+			var out strings.Builder
+			got, err := choosePath(selected, decisions, strings.NewReader(tc.input), &out)
+			if tc.first < 0 {
+				if err == nil {
+					t.Errorf("choosePath() = %v, want error", choiceIDs(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []int{tc.first}
+			for i := range selected {
+				if i != tc.first {
+					want = append(want, i)
+				}
+			}
+			if ids := choiceIDs(got); !reflect.DeepEqual(ids, want) {
+				t.Errorf("choosePath() = %v, want %v", ids, want)
+			}
+		})
+	}
+}
+
+func TestPromptIndex(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  int
+		// invalid is the number of rejected answers.
+		invalid int
+		// readErr makes reading fail after input.
+		readErr bool
+		err     bool
+	}{
+		"first":            {input: "0\n", want: 0},
+		"last":             {input: "2\n", want: 2},
+		"default":          {input: "\n", want: 0},
+		"no newline":       {input: "1", want: 1},
+		"spaces":           {input: "  1 \n", want: 1},
+		"out of range":     {input: "3\n1\n", want: 1, invalid: 1},
+		"negative":         {input: "-1\n2\n", want: 2, invalid: 1},
+		"not a number":     {input: "one\n0x1\n1\n", want: 1, invalid: 2},
+		"end of input":     {input: "", err: true},
+		"only invalid":     {input: "3\n4\n", invalid: 2, err: true},
+		"read error":       {readErr: true, err: true},
+		"first line wins":  {input: "2\n1\n", want: 2},
+		"prompt each time": {input: "x\ny\nz\n0\n", want: 0, invalid: 3},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var r io.Reader = strings.NewReader(tc.input)
+			if tc.readErr {
+				r = io.MultiReader(r, errReader{})
+			}
+			var out strings.Builder
+			got, err := promptIndex(r, &out, 3)
+			if tc.err {
+				if err == nil {
+					t.Errorf("promptIndex() = %d, want error", got)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if got != tc.want {
+				t.Errorf("promptIndex() = %d, want %d", got, tc.want)
+			}
+			if n := strings.Count(out.String(), "Invalid choice"); n != tc.invalid {
+				t.Errorf("%d answers rejected, want %d:\n%s", n, tc.invalid, out.String())
+			}
+			if !strings.HasPrefix(out.String(), "Choose path [0-2, default 0]: ") {
+				t.Errorf("prompt = %q", out.String())
+			}
+		})
+	}
+}
+
+// errReader fails every read.
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("terminal gone")
+}
+
+func TestFindFingerprint(t *testing.T) {
+	paths := choicePaths(t, 2)
+	candidates := []candidate{{path: paths[0]}, {path: paths[1]}}
+	fp := snet.Fingerprint(paths[1]).String()
+	for _, prefix := range []string{fp, fp[:8], strings.ToUpper(fp[:8])} {
+		if i, err := findFingerprint(candidates, prefix); err != nil || i != 1 {
+			t.Errorf("findFingerprint(%q) = %d, %v, want 1", prefix, i, err)
+		}
+	}
+	// Duplicate candidates share every prefix.
+	dup := append(candidates, candidate{path: paths[1]})
+	if _, err := findFingerprint(dup, fp[:8]); err == nil {
+		t.Errorf("findFingerprint() of an ambiguous prefix succeeded")
+	}
+	if _, err := findFingerprint(candidates, "xyz"); err == nil {
+		t.Errorf("findFingerprint() of an unknown prefix succeeded")
+	}
+}
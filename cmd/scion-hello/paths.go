@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/private/serrors"
+
+	"github.com/tzaeschke/scion-hello/config"
+	"github.com/tzaeschke/scion-hello/connect"
+	"github.com/tzaeschke/scion-hello/pathinfo"
+)
+
+// pathsCfg are the flags of the paths command.
+var pathsCfg struct {
+	dst     addr.IA
+	format  pathinfo.Format
+	refresh bool
+}
+
+func init() {
+	register(&command{
+		name:    "paths",
+		summary: "List the paths to an AS as JSON, YAML or CSV",
+		defaults: config.Defaults{
+			Daemon: "[127.0.0.12]:30255", // from 110-topo
+			Local:  "1-ff00:0:110,127.0.0.2:12345",
+		},
+		flags: func(fs *flag.FlagSet, cfg *config.Config) {
+			fs.Var(&pathsCfg.dst, "dst", "destination AS, e.g. 1-ff00:0:112 (required)")
+			fs.Var(&pathsCfg.format, "format",
+				"output format, one of "+strings.Join(pathinfo.Formats(), ", ")+
+					" (default json)")
+			fs.BoolVar(&pathsCfg.refresh, "refresh", false,
+				"ask the daemon to fetch fresh paths")
+			registerPathFlags(fs)
+		},
+		run: runPaths,
+	})
+}
+
+func runPaths(ctx context.Context, cfg *config.Config) error {
+	if pathsCfg.dst.IsZero() {
+		return serrors.New("missing destination AS, use -dst")
+	}
+	dc, err := connect.Daemon(ctx, cfg.Daemon)
+	if err != nil {
+		return err
+	}
+	defer dc.Close()
+
+	paths, err := dc.Paths(ctx, pathsCfg.dst, cfg.Local.IA,
+		daemon.PathReqFlags{Refresh: pathsCfg.refresh})
+	if err != nil {
+		return serrors.WrapStr("requesting paths", err, "dst", pathsCfg.dst)
+	}
+	if paths, err = selectPaths(paths); err != nil {
+		return err
+	}
+	list := pathinfo.NewList(cfg.Local.IA, pathsCfg.dst, paths)
+	return list.Write(os.Stdout, pathsCfg.format)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+	"github.com/scionproto/scion/private/path/pathpol"
+
+	"github.com/tzaeschke/scion-hello/pathsel"
+)
+
+// pathSelection selects the paths of the client commands.
+var pathSelection pathsel.Selector
+
+// registerPathFlags registers the path selection flags of the client commands.
+func registerPathFlags(fs *flag.FlagSet) {
+	fs.Var(&pathSelection.Strategy, "path-strategy",
+		"rank the paths by one of "+strings.Join(pathsel.Strategies(), ", ")+
+			" (default shortest)")
+	fs.Var((*isdList)(&pathSelection.Filter.ISDs), "exclude-isd",
+		"ISD the path must not cross, e.g. 2 (repeatable)")
+	fs.Var((*asList)(&pathSelection.Filter.ASes), "exclude-as",
+		"AS the path must not cross, e.g. ff00:0:111 (repeatable)")
+	fs.Var((*linkTypeList)(&pathSelection.Filter.LinkTypes), "exclude-link",
+		"type of the links the path must not use, e.g. opennet (repeatable)")
+	fs.Var(policyFile{&pathSelection.Policy}, "path-policy",
+		"JSON or YAML file with a SCION path policy the path must satisfy")
+}
+
+// selectPaths returns the paths chosen by the path selection flags, the best or the path
+// chosen by hand first. If paths are excluded, it logs the decision for every path.
+func selectPaths(paths []snet.Path) ([]snet.Path, error) {
+	selected, decisions := pathSelection.Evaluate(paths)
+	logDecision := log.Debug
+	if len(selected) < len(paths) {
+		logDecision = log.Info
+	}
+	for i, d := range decisions {
+		if d.Excluded != nil {
+			logDecision("Path excluded", "candidate", i, "path", d.Path,
+				"reason", d.Excluded.Error())
+		} else {
+			logDecision("Path included", "candidate", i, "path", d.Path)
+		}
+	}
+	if len(selected) == 0 && len(paths) > 0 {
+		return nil, serrors.New("all paths excluded", "candidates", len(paths))
+	}
+	// The terminal is only needed for the prompt, the other choices take precedence.
+	if !pathChoice.interactive || pathChoice.index >= 0 || pathChoice.fingerprint != "" {
+		return choosePath(selected, decisions, nil, nil)
+	}
+	tty, err := openTerminal()
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+	return choosePath(selected, decisions, tty, tty)
+}
+
+// policyFile is a flag loading a path policy from a file.
+type policyFile struct {
+	policy **pathpol.Policy
+}
+
+func (f policyFile) String() string {
+	if f.policy == nil || *f.policy == nil {
+		return ""
+	}
+	return (*f.policy).Name
+}
+
+func (f policyFile) Set(file string) error {
+	policy, err := pathsel.LoadPolicy(file)
+	if err != nil {
+		return err
+	}
+	*f.policy = policy
+	return nil
+}
+
+// isdList is a repeatable flag of ISDs.
+type isdList []addr.ISD
+
+func (l *isdList) String() string {
+	s := make([]string, 0, len(*l))
+	for _, isd := range *l {
+		s = append(s, strconv.Itoa(int(isd)))
+	}
+	return strings.Join(s, " ")
+}
+
+func (l *isdList) Set(s string) error {
+	isd, err := addr.ParseISD(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, isd)
+	return nil
+}
+
+// asList is a repeatable flag of ASes.
+type asList []addr.AS
+
+func (l *asList) String() string {
+	s := make([]string, 0, len(*l))
+	for _, as := range *l {
+		s = append(s, as.String())
+	}
+	return strings.Join(s, " ")
+}
+
+func (l *asList) Set(s string) error {
+	as, err := addr.ParseAS(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, as)
+	return nil
+}
+
+// linkTypeList is a repeatable flag of link types.
+type linkTypeList []snet.LinkType
+
+func (l *linkTypeList) String() string {
+	s := make([]string, 0, len(*l))
+	for _, lt := range *l {
+		s = append(s, lt.String())
+	}
+	return strings.Join(s, " ")
+}
+
+func (l *linkTypeList) Set(s string) error {
+	lt, err := pathsel.ParseLinkType(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, lt)
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"github.com/tzaeschke/scion-hello/pathsel"
+)
+
+func TestPathFlags(t *testing.T) {
+	as110, err := addr.ParseAS("ff00:0:110")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		args []string
+		// want is the selection, nil if the flags are invalid.
+		want *pathsel.Selector
+	}{
+		"none": {want: &pathsel.Selector{}},
+		"strategy": {
+			args: []string{"-path-strategy", "latency"},
+			want: &pathsel.Selector{Strategy: pathsel.Latency},
+		},
+		"exclude ISDs": {
+			args: []string{"-exclude-isd", "2", "-exclude-isd", "3"},
+			want: &pathsel.Selector{Filter: pathsel.Filter{ISDs: []addr.ISD{2, 3}}},
+		},
+		"exclude AS": {
+			args: []string{"-exclude-as", "ff00:0:110"},
+			want: &pathsel.Selector{Filter: pathsel.Filter{ASes: []addr.AS{as110}}},
+		},
+		"exclude links": {
+			args: []string{"-exclude-link", "opennet", "-exclude-link", "multihop"},
+			want: &pathsel.Selector{Filter: pathsel.Filter{
+				LinkTypes: []snet.LinkType{snet.LinkTypeOpennet, snet.LinkTypeMultihop},
+			}},
+		},
+		"unknown strategy": {args: []string{"-path-strategy", "fastest"}},
+		"invalid ISD":      {args: []string{"-exclude-isd", "x"}},
+		"invalid AS":       {args: []string{"-exclude-as", "1-ff00:0:110"}},
+		"unknown link":     {args: []string{"-exclude-link", "fiber"}},
+	}
+	defer func() { pathSelection = pathsel.Selector{} }()
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pathSelection = pathsel.Selector{}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			registerPathFlags(fs)
+			err := fs.Parse(tc.args)
+			if tc.want == nil {
+				if err == nil {
+					t.Errorf("Parse(%q) succeeded, want error", tc.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pathSelection, *tc.want) {
+				t.Errorf("selection = %+v, want %+v", pathSelection, *tc.want)
+			}
+		})
+	}
+}
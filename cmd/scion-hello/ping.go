@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"github.com/tzaeschke/scion-hello/config"
+	"github.com/tzaeschke/scion-hello/connect"
+	"github.com/tzaeschke/scion-hello/echo"
+	"github.com/tzaeschke/scion-hello/monitor"
+)
+
+// pingFlags are the flags of the ping command.
+type pingFlags struct {
+	count    uint
+	interval time.Duration
+	size     uint
+	timeout  time.Duration
+}
+
+var pingCfg = pingFlags{interval: time.Second, size: 64, timeout: time.Second}
+
+// pingFill is repeated to pad the probes to the requested size.
+var pingFill = []byte("Hello scion ")
+
+func init() {
+	register(&command{
+		name:    "ping",
+		summary: "Ping a hello server with UDP echo probes and print RTT statistics",
+		defaults: config.Defaults{
+			Daemon: "[127.0.0.12]:30255", // from 110-topo
+			Local:  "1-ff00:0:110,127.0.0.2:0",
+			Remote: "1-ff00:0:112,[::1]:8080",
+		},
+		flags: func(fs *flag.FlagSet, cfg *config.Config) {
+			cfg.RegisterRemoteFlag(fs)
+			registerPathFlags(fs)
+			registerPathChoiceFlags(fs)
+			fs.UintVar(&pingCfg.count, "c", 0,
+				"number of probes to send (default until interrupted)")
+			fs.DurationVar(&pingCfg.interval, "i", pingCfg.interval, "time between the probes")
+			fs.UintVar(&pingCfg.size, "s", pingCfg.size,
+				fmt.Sprintf("UDP payload size of the probes in bytes, at least %d", echo.HeaderLen))
+			fs.DurationVar(&pingCfg.timeout, "W", pingCfg.timeout,
+				"time to wait for a reply; later replies are not counted")
+		},
+		run: runPing,
+	})
+}
+
+func runPing(ctx context.Context, cfg *config.Config) error {
+	if err := pingCfg.validate(); err != nil {
+		return err
+	}
+	host, err := connect.Open(ctx, cfg.Daemon, &cfg.Local)
+	if err != nil {
+		return err
+	}
+	defer host.Close()
+
+	remote := cfg.Remote
+	paths, err := host.Daemon.Paths(ctx, remote.IA, host.IA, daemon.PathReqFlags{})
+	if err != nil {
+		return serrors.WrapStr("requesting paths", err)
+	}
+	if len(paths) == 0 {
+		return serrors.New("no paths found", "src", host.IA, "dst", remote.IA)
+	}
+	if paths, err = selectPaths(paths); err != nil {
+		return err
+	}
+	path := paths[0]
+	nextHop := path.UnderlayNextHop()
+	if nextHop == nil && remote.IA.Equal(host.IA) {
+		// Within the AS, the packets go straight to the server.
+		nextHop = &net.UDPAddr{IP: remote.Host.IP, Port: remote.Host.Port, Zone: remote.Host.Zone}
+	}
+	local := snet.UDPAddr{IA: host.IA, Host: host.LocalAddr()}
+	fmt.Printf("Resolved local address:\n  %v\n", local.Host.IP)
+	fmt.Printf("Using path:\n  %v\n\n", path)
+
+	var m *monitor.Client
+	if cfg.MetricsAddr != "" {
+		m = monitor.NewClientMetrics(&monitor.LabelLimit{Max: cfg.MetricsMaxPaths})
+	}
+	p := &pinger{
+		conn:        host.Conn,
+		local:       local,
+		remote:      remote,
+		path:        path.Dataplane(),
+		nextHop:     nextHop,
+		metrics:     m,
+		fingerprint: echo.DataplaneFingerprint(path.Dataplane()),
+		tracker:     echo.NewTracker(),
+	}
+	pktLen, err := p.packetLen()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("PING %v pld=%dB scion_pkt=%dB\n", &remote, pingCfg.size, pktLen)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	start := time.Now()
+	err = p.run(ctx)
+	stats := p.tracker.Stats()
+	fmt.Printf("\n--- %v statistics ---\n", &remote)
+	fmt.Printf("%d packets transmitted, %d received, %s packet loss, time %v\n",
+		stats.Sent, stats.Answered, lossRate(stats), time.Since(start).Round(time.Millisecond))
+	if p.rtts.n > 0 {
+		fmt.Printf("rtt min/avg/max/mdev = %s ms\n", &p.rtts)
+	}
+	if stats.Duplicates > 0 || stats.Reordered > 0 {
+		fmt.Printf("%d duplicates, %d reordered\n", stats.Duplicates, stats.Reordered)
+	}
+	if err != nil {
+		return err
+	}
+	if stats.Answered == 0 {
+		return serrors.New("no reply received", "remote", &remote)
+	}
+	return nil
+}
+
+func (f *pingFlags) validate() error {
+	if f.interval <= 0 {
+		return serrors.New("interval must be positive", "interval", f.interval)
+	}
+	if f.timeout <= 0 {
+		return serrors.New("timeout must be positive", "timeout", f.timeout)
+	}
+	if f.size < echo.HeaderLen || f.size > math.MaxUint16 {
+		return serrors.New("invalid payload size", "size", f.size, "min", echo.HeaderLen,
+			"max", math.MaxUint16)
+	}
+	return nil
+}
+
+// pinger sends the probes of the ping command and matches the replies.
+type pinger struct {
+	conn        snet.PacketConn
+	local       snet.UDPAddr
+	remote      snet.UDPAddr
+	path        snet.DataplanePath
+	nextHop     *net.UDPAddr
+	metrics     *monitor.Client
+	fingerprint string
+
+	// mu protects the tracker and the RTTs, which are shared by the sender and the receiver.
+	mu      sync.Mutex
+	tracker *echo.Tracker
+	rtts    rttStats
+	// sent is set once the last probe was sent.
+	sent bool
+}
+
+// run sends the probes and receives the replies until all probes were sent and answered or
+// timed out, or until ctx is done.
+func (p *pinger) run(ctx context.Context) error {
+	// Stop sending if receiving fails.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	received := make(chan error, 1)
+	go func() {
+		defer log.HandlePanic()
+		err := p.receive()
+		if err != nil {
+			cancel()
+		}
+		received <- err
+	}()
+	sendErr := p.send(ctx)
+
+	p.mu.Lock()
+	p.sent = true
+	deadline := time.Now().Add(pingCfg.timeout)
+	if p.tracker.Pending() == 0 || ctx.Err() != nil || sendErr != nil {
+		deadline = time.Now()
+	}
+	p.mu.Unlock()
+	// Unblock the receiver once the last probe timed out.
+	if err := p.conn.SetReadDeadline(deadline); err != nil {
+		return serrors.WrapStr("setting read deadline", err)
+	}
+	if err := <-received; err != nil {
+		return err
+	}
+	return sendErr
+}
+
+// send sends a probe every interval until count probes were sent or ctx is done.
+func (p *pinger) send(ctx context.Context) error {
+	ticker := time.NewTicker(pingCfg.interval)
+	defer ticker.Stop()
+	for i := uint(0); pingCfg.count == 0 || i < pingCfg.count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+		now := time.Now()
+		p.mu.Lock()
+		p.tracker.Expire(now.Add(-pingCfg.timeout))
+		hdr := p.tracker.Next(now)
+		p.mu.Unlock()
+		pkt, err := p.probe(hdr)
+		if err != nil {
+			return err
+		}
+		if err := p.conn.WriteTo(pkt, p.nextHop); err != nil {
+			return serrors.WrapStr("sending probe", err, "seq", hdr.Seq)
+		}
+	}
+	return nil
+}
+
+// receive prints the replies until the read deadline set by run.
+func (p *pinger) receive() error {
+	for {
+		var pkt snet.Packet
+		var ov net.UDPAddr
+		if err := p.conn.ReadFrom(&pkt, &ov); err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil
+			}
+			// SCMP errors, e.g. an unreachable destination, do not stop pinging.
+			var opErr *snet.OpError
+			if errors.As(err, &opErr) {
+				fmt.Printf("SCMP error: %v\n", opErr)
+				continue
+			}
+			return serrors.WrapStr("reading reply", err)
+		}
+		now := time.Now()
+		udp, ok := pkt.Payload.(snet.UDPPayload)
+		if !ok {
+			continue
+		}
+		hdr, _, err := echo.ParseHeader(udp.Payload)
+		if err != nil {
+			log.Debug("Ignoring reply without message header", "src", pkt.Source, "err", err)
+			continue
+		}
+		if done := p.match(hdr, now, pkt.Source, udp); done {
+			return nil
+		}
+	}
+}
+
+// match matches and prints the reply with header hdr received at now. It returns true once
+// the last probe was answered.
+func (p *pinger) match(hdr echo.Header, now time.Time, src snet.SCIONAddress,
+	udp snet.UDPPayload) bool {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tracker.Expire(now.Add(-pingCfg.timeout))
+	reply := p.tracker.Match(hdr, now)
+	p.metrics.Observe(p.remote.IA, p.fingerprint, reply)
+	from := echo.UDPAddr(src, udp.SrcPort)
+	switch reply.Kind {
+	case echo.InOrder, echo.Reordered:
+		p.rtts.add(reply.RTT)
+		suffix := ""
+		if reply.Kind == echo.Reordered {
+			suffix = " (reordered)"
+		}
+		fmt.Printf("%d bytes from %v: seq=%d time=%v%s\n", len(udp.Payload), &from,
+			reply.Seq, reply.RTT.Round(time.Microsecond), suffix)
+	case echo.Duplicate:
+		fmt.Printf("%d bytes from %v: seq=%d (DUP!)\n", len(udp.Payload), &from, reply.Seq)
+	default:
+		log.Debug("Ignoring stray reply", "src", &from, "seq", reply.Seq)
+	}
+	return p.sent && p.tracker.Pending() == 0
+}
+
+// probe returns the packet of the probe with header hdr.
+func (p *pinger) probe(hdr echo.Header) (*snet.Packet, error) {
+	remoteIP, ok := netip.AddrFromSlice(p.remote.Host.IP)
+	if !ok {
+		return nil, serrors.New("invalid remote host IP", "ip", p.remote.Host.IP)
+	}
+	localIP, ok := netip.AddrFromSlice(p.local.Host.IP)
+	if !ok {
+		return nil, serrors.New("invalid local host IP", "ip", p.local.Host.IP)
+	}
+	payload := hdr.AppendTo(make([]byte, 0, pingCfg.size))
+	for len(payload) < int(pingCfg.size) {
+		payload = append(payload, pingFill[:min(len(pingFill), int(pingCfg.size)-len(payload))]...)
+	}
+	return &snet.Packet{
+		PacketInfo: snet.PacketInfo{
+			Destination: snet.SCIONAddress{IA: p.remote.IA, Host: addr.HostIP(remoteIP.Unmap())},
+			Source:      snet.SCIONAddress{IA: p.local.IA, Host: addr.HostIP(localIP.Unmap())},
+			Path:        p.path,
+			Payload: snet.UDPPayload{
+				SrcPort: uint16(p.local.Host.Port),
+				DstPort: uint16(p.remote.Host.Port),
+				Payload: payload,
+			},
+		},
+	}, nil
+}
+
+// packetLen returns the length of the SCION packets of the probes.
+func (p *pinger) packetLen() (int, error) {
+	pkt, err := p.probe(echo.Header{Version: echo.Version})
+	if err != nil {
+		return 0, err
+	}
+	if err := pkt.Serialize(); err != nil {
+		return 0, serrors.WrapStr("serializing probe", err)
+	}
+	return len(pkt.Bytes), nil
+}
+
+// lossRate returns the share of unanswered requests in percent.
+func lossRate(s echo.TrackerStats) string {
+	if s.Sent == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.3g%%", 100*float64(s.Sent-s.Answered)/float64(s.Sent))
+}
+
+// rttStats are the statistics of the round trip times.
+type rttStats struct {
+	n          int
+	min, max   time.Duration
+	sum, sumSq float64
+}
+
+func (s *rttStats) add(rtt time.Duration) {
+	if s.n == 0 || rtt < s.min {
+		s.min = rtt
+	}
+	if rtt > s.max {
+		s.max = rtt
+	}
+	ms := float64(rtt) / float64(time.Millisecond)
+	s.n++
+	s.sum += ms
+	s.sumSq += ms * ms
+}
+
+// String returns min/avg/max/mdev in milliseconds.
+func (s *rttStats) String() string {
+	avg := s.sum / float64(s.n)
+	mdev := math.Sqrt(math.Max(0, s.sumSq/float64(s.n)-avg*avg))
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return fmt.Sprintf("%.3f/%.3f/%.3f/%.3f", ms(s.min), avg, ms(s.max), mdev)
+}
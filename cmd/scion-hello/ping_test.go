@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+
+	"github.com/tzaeschke/scion-hello/echo"
+)
+
+func TestRTTStats(t *testing.T) {
+	tests := map[string]struct {
+		rtts []time.Duration
+		// want is min/avg/max/mdev in milliseconds.
+		want string
+	}{
+		"one":        {rtts: []time.Duration{time.Millisecond}, want: "1.000/1.000/1.000/0.000"},
+		"equal":      {rtts: []time.Duration{2e6, 2e6, 2e6}, want: "2.000/2.000/2.000/0.000"},
+		"increasing": {rtts: []time.Duration{1e6, 2e6, 3e6}, want: "1.000/2.000/3.000/0.816"},
+		"unordered":  {rtts: []time.Duration{3e6, 1e6}, want: "1.000/2.000/3.000/1.000"},
+		"sub-ms":     {rtts: []time.Duration{250e3, 750e3}, want: "0.250/0.500/0.750/0.250"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var s rttStats
+			for _, rtt := range tc.rtts {
+				s.add(rtt)
+			}
+			if s.n != len(tc.rtts) {
+				t.Errorf("n = %d, want %d", s.n, len(tc.rtts))
+			}
+			if got := s.String(); got != tc.want {
+				t.Errorf("String() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// echoConn is an snet.PacketConn that answers the probes of a pinger with copies copies of
+// each probe, after delay.
+type echoConn struct {
+	snet.PacketConn
+	copies int
+	delay  time.Duration
+
+	replies chan *snet.Packet
+	mu      sync.Mutex
+	// deadline is the read deadline. wake is closed when it changes.
+	deadline time.Time
+	wake     chan struct{}
+}
+
+func newEchoConn(copies int, delay time.Duration) *echoConn {
+	return &echoConn{
+		copies:  copies,
+		delay:   delay,
+		replies: make(chan *snet.Packet, 1024),
+		wake:    make(chan struct{}),
+	}
+}
+
+func (c *echoConn) WriteTo(pkt *snet.Packet, ov *net.UDPAddr) error {
+	udp := pkt.Payload.(snet.UDPPayload)
+	reply := &snet.Packet{PacketInfo: snet.PacketInfo{
+		Source:      pkt.Destination,
+		Destination: pkt.Source,
+		Payload: snet.UDPPayload{
+			SrcPort: udp.DstPort,
+			DstPort: udp.SrcPort,
+			Payload: append([]byte(nil), udp.Payload...),
+		},
+	}}
+	send := func() {
+		for i := 0; i < c.copies; i++ {
+			c.replies <- reply
+		}
+	}
+	if c.delay == 0 {
+		send()
+	} else {
+		time.AfterFunc(c.delay, send)
+	}
+	return nil
+}
+
+func (c *echoConn) ReadFrom(pkt *snet.Packet, ov *net.UDPAddr) error {
+	for {
+		c.mu.Lock()
+		deadline, wake := c.deadline, c.wake
+		c.mu.Unlock()
+		var expired <-chan time.Time
+		timer := time.NewTimer(time.Until(deadline))
+		if !deadline.IsZero() {
+			expired = timer.C
+		}
+		select {
+		case r := <-c.replies:
+			timer.Stop()
+			*pkt = *r
+			return nil
+		case <-expired:
+			return os.ErrDeadlineExceeded
+		case <-wake:
+			timer.Stop()
+		}
+	}
+}
+
+func (c *echoConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	close(c.wake)
+	c.wake = make(chan struct{})
+	return nil
+}
+
+// TestPingerCountAndTimeout tests how the pinger handles -c, the number of probes, and -W,
+// the time to wait for a reply.
+func TestPingerCountAndTimeout(t *testing.T) {
+	ia, err := addr.ParseIA("1-ff00:0:110")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		count   uint
+		timeout time.Duration
+		// copies and delay describe the replies to each probe.
+		copies int
+		delay  time.Duration
+		// stop stops pinging after this time, if set.
+		stop time.Duration
+		// want are the stats of the tracker. If count is 0, it is only checked that the
+		// probes are answered; the reply to the last one may be ignored once pinging stops.
+		want echo.TrackerStats
+		// waits is set if the pinger must wait for the timeout after the last probe.
+		waits bool
+	}{
+		"all answered": {
+			count:   3,
+			timeout: 5 * time.Second,
+			copies:  1,
+			want:    echo.TrackerStats{Sent: 3, Answered: 3},
+		},
+		"none answered": {
+			count:   2,
+			timeout: 100 * time.Millisecond,
+			want:    echo.TrackerStats{Sent: 2},
+			waits:   true,
+		},
+		"duplicates": {
+			count:   2,
+			timeout: 5 * time.Second,
+			copies:  2,
+			want:    echo.TrackerStats{Sent: 2, Answered: 2, Duplicates: 1},
+		},
+		"replies after timeout": {
+			count:   1,
+			timeout: 100 * time.Millisecond,
+			copies:  1,
+			delay:   time.Second,
+			want:    echo.TrackerStats{Sent: 1},
+			waits:   true,
+		},
+		"until stopped": {
+			timeout: 5 * time.Second,
+			copies:  1,
+			stop:    200 * time.Millisecond,
+		},
+	}
+	defer func(cfg pingFlags) { pingCfg = cfg }(pingCfg)
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pingCfg = pingFlags{count: tc.count, interval: 20 * time.Millisecond, size: 64,
+				timeout: tc.timeout}
+			if err := pingCfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+			p := &pinger{
+				conn:    newEchoConn(tc.copies, tc.delay),
+				local:   snet.UDPAddr{IA: ia, Host: &net.UDPAddr{IP: net.IP{127, 0, 0, 2}}},
+				remote:  snet.UDPAddr{IA: ia, Host: &net.UDPAddr{IP: net.IP{127, 0, 0, 1}}},
+				path:    snetpath.Empty{},
+				tracker: echo.NewTracker(),
+			}
+			ctx := context.Background()
+			if tc.stop != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.stop)
+				defer cancel()
+			}
+			start := time.Now()
+			if err := p.run(ctx); err != nil {
+				t.Fatal(err)
+			}
+			elapsed := time.Since(start)
+
+			stats := p.tracker.Stats()
+			if tc.count == 0 {
+				if stats.Sent < 2 || stats.Answered+1 < stats.Sent {
+					t.Errorf("Stats() = %+v, want several probes answered", stats)
+				}
+			} else if stats != tc.want {
+				t.Errorf("Stats() = %+v, want %+v", stats, tc.want)
+			}
+			if p.rtts.n != int(stats.Answered) {
+				t.Errorf("%d RTTs, want %d", p.rtts.n, stats.Answered)
+			}
+			if tc.waits && elapsed < tc.timeout {
+				t.Errorf("ping took %v, want at least the timeout %v", elapsed, tc.timeout)
+			}
+			if !tc.waits && elapsed > time.Second {
+				t.Errorf("ping took %v, want to stop once all probes are answered", elapsed)
+			}
+		})
+	}
+}
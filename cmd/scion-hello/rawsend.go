@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"github.com/tzaeschke/scion-hello/config"
+	"github.com/tzaeschke/scion-hello/connect"
+)
+
+// endHostPort is the port on which dispatcher-less end hosts receive SCION packets from the
+// border router.
+const endHostPort = 30041
+
+func init() {
+	register(&command{
+		name:    "raw-send",
+		summary: "Send a hello packet over a plain UDP socket, acting as its own dispatcher",
+		flags: func(fs *flag.FlagSet, cfg *config.Config) {
+			cfg.RegisterRemoteFlag(fs)
+		},
+		run: func(ctx context.Context, cfg *config.Config) error {
+			return sendHello(ctx, cfg.Daemon, cfg.Local, cfg.Remote)
+		},
+	})
+}
+
+func sendHello(ctx context.Context, daemonAddr string, localAddr snet.UDPAddr,
+	remoteAddr snet.UDPAddr) error {
+
+	dc, err := connect.Daemon(ctx, daemonAddr)
+	if err != nil {
+		return err
+	}
+	defer dc.Close()
+
+	ps, err := dc.Paths(ctx, remoteAddr.IA, localAddr.IA, daemon.PathReqFlags{Refresh: true})
+	if err != nil {
+		return serrors.WrapStr("looking up paths", err)
+	}
+
+	if len(ps) == 0 {
+		return serrors.New("no paths available", "remote", remoteAddr.IA)
+	}
+
+	log.Info("Available paths", "remote", remoteAddr.IA)
+	for _, p := range ps {
+		log.Info(fmt.Sprintf("\t%v", p))
+	}
+
+	sp := ps[0]
+
+	log.Info("Selected path", "remote", remoteAddr.IA, "path", sp)
+
+	lconn, err := net.ListenUDP("udp", &net.UDPAddr{IP: localAddr.Host.IP})
+	if err != nil {
+		return serrors.WrapStr("binding UDP connection", err)
+	}
+	defer lconn.Close()
+
+	localAddr.Host.Port = lconn.LocalAddr().(*net.UDPAddr).Port
+
+	localHostIP, ok := netip.AddrFromSlice(localAddr.Host.IP)
+	if !ok {
+		return serrors.New("invalid local host IP", "ip", localAddr.Host.IP)
+	}
+	remoteHostIP, ok := netip.AddrFromSlice(remoteAddr.Host.IP)
+	if !ok {
+		return serrors.New("invalid remote host IP", "ip", remoteAddr.Host.IP)
+	}
+	pkt := &snet.Packet{
+		PacketInfo: snet.PacketInfo{
+			Source: snet.SCIONAddress{
+				IA:   localAddr.IA,
+				Host: addr.HostIP(localHostIP),
+			},
+			Destination: snet.SCIONAddress{
+				IA:   remoteAddr.IA,
+				Host: addr.HostIP(remoteHostIP),
+			},
+			Path: sp.Dataplane(),
+			Payload: snet.UDPPayload{
+				SrcPort: uint16(localAddr.Host.Port),
+				DstPort: uint16(remoteAddr.Host.Port),
+				Payload: []byte("Hello, world!"),
+			},
+		},
+	}
+
+	nextHop := sp.UnderlayNextHop()
+	if nextHop == nil && remoteAddr.IA.Equal(localAddr.IA) {
+		nextHop = &net.UDPAddr{
+			IP:   remoteAddr.Host.IP,
+			Port: endHostPort,
+			Zone: remoteAddr.Host.Zone,
+		}
+	}
+
+	if err := pkt.Serialize(); err != nil {
+		return serrors.WrapStr("serializing SCION packet", err)
+	}
+
+	dconn, err := net.ListenUDP("udp", &net.UDPAddr{
+		IP:   localAddr.Host.IP,
+		Port: endHostPort,
+		Zone: localAddr.Host.Zone,
+	})
+	if err != nil {
+		return serrors.WrapStr("binding end host port", err)
+	}
+	defer dconn.Close()
+
+	if _, err := lconn.WriteTo(pkt.Bytes, nextHop); err != nil {
+		return serrors.WrapStr("writing packet", err)
+	}
+
+	pkt.Prepare()
+	n, lastHop, err := dconn.ReadFrom(pkt.Bytes)
+	if err != nil {
+		return serrors.WrapStr("reading packet", err)
+	}
+	pkt.Bytes = pkt.Bytes[:n]
+
+	log.Debug("[D]: received from "+lastHop.String(), "bytes", hex.Dump(pkt.Bytes))
+
+	if err := pkt.Decode(); err != nil {
+		return serrors.WrapStr("decoding packet", err)
+	}
+
+	pld, ok := pkt.Payload.(snet.UDPPayload)
+	if !ok {
+		return serrors.New("unexpected payload", "type", common.TypeOf(pkt.Payload))
+	}
+
+	dst := netip.AddrPortFrom(pkt.Destination.Host.IP(), pld.DstPort)
+	m, err := dconn.WriteTo(pkt.Bytes, net.UDPAddrFromAddrPort(dst))
+	if err != nil {
+		return serrors.WrapStr("forwarding packet", err)
+	}
+	if m != n {
+		return serrors.New("forwarding packet: short write", "written", m, "expected", n)
+	}
+
+	pkt.Prepare()
+	n, lastHop, err = lconn.ReadFrom(pkt.Bytes)
+	if err != nil {
+		return serrors.WrapStr("reading packet", err)
+	}
+	pkt.Bytes = pkt.Bytes[:n]
+
+	log.Debug("[L]: received from "+lastHop.String(), "bytes", hex.Dump(pkt.Bytes))
+
+	if err := pkt.Decode(); err != nil {
+		return serrors.WrapStr("decoding packet", err)
+	}
+
+	pld, ok = pkt.Payload.(snet.UDPPayload)
+	if !ok {
+		return serrors.New("unexpected payload", "type", common.TypeOf(pkt.Payload))
+	}
+	log.Info("Received answer", "from", pkt.Source, "payload", string(pld.Payload))
+	return nil
+}
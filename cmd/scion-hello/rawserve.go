@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"github.com/tzaeschke/scion-hello/config"
+	"github.com/tzaeschke/scion-hello/replypath"
+)
+
+func init() {
+	register(&command{
+		name:    "raw-serve",
+		summary: "Answer hello packets and SCMP echo and traceroute requests on the end host port",
+		run: func(ctx context.Context, cfg *config.Config) error {
+			return runServer(ctx, cfg.Local)
+		},
+	})
+}
+
+// runServer answers the packets on the end host port until ctx is done. It returns ctx.Err()
+// if ctx is done.
+func runServer(ctx context.Context, localAddr snet.UDPAddr) error {
+	listen := &net.UDPAddr{
+		IP:   localAddr.Host.IP,
+		Port: endHostPort,
+		Zone: localAddr.Host.Zone,
+	}
+
+	log.Info("Listening", "isd_as", localAddr.IA, "local", listen, "svc", addr.SvcNone)
+
+	conn, err := net.ListenUDP("udp", listen)
+	if err != nil {
+		return serrors.WrapStr("listening on UDP connection", err)
+	}
+	defer conn.Close()
+	// Unblock the pending read once ctx is done.
+	stop := context.AfterFunc(ctx, func() {
+		if err := conn.SetReadDeadline(time.Now()); err != nil {
+			log.Error("Failed to interrupt read", "err", err)
+		}
+	})
+	defer stop()
+
+	var backoff time.Duration
+	for {
+		var pkt snet.Packet
+		pkt.Prepare()
+		n, lastHop, err := conn.ReadFrom(pkt.Bytes)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return serrors.WrapStr("reading packet", err)
+			}
+			if err := retryRead(ctx, &backoff, err); err != nil {
+				return err
+			}
+			continue
+		}
+		backoff = 0
+
+		pkt.Bytes = pkt.Bytes[:n]
+		err = pkt.Decode()
+		if err != nil {
+			log.Error("Failed to decode packet", "err", err)
+			continue
+		}
+
+		var reply snet.Payload
+		switch pld := pkt.Payload.(type) {
+		case snet.UDPPayload:
+			log.Info("Received payload", "payload", string(pld.Payload))
+			reply = snet.UDPPayload{
+				DstPort: pld.SrcPort,
+				SrcPort: pld.DstPort,
+				Payload: []byte("!DLROW ,OLLEh"),
+			}
+		case snet.SCMPEchoRequest:
+			// The router of the client delivers the reply to the port given by the
+			// identifier, as there is no dispatcher that remembers it.
+			log.Info("Received SCMP echo request", "src", pkt.Source,
+				"id", pld.Identifier, "seq", pld.SeqNumber)
+			reply = snet.SCMPEchoReply{
+				Identifier: pld.Identifier,
+				SeqNumber:  pld.SeqNumber,
+				Payload:    pld.Payload,
+			}
+		case snet.SCMPTracerouteRequest:
+			log.Info("Received SCMP traceroute request", "src", pkt.Source,
+				"id", pld.Identifier, "seq", pld.Sequence)
+			// The end host answers rather than a border router, so the reply is not
+			// from an interface of the AS. Interface 0 is not a valid interface ID.
+			reply = snet.SCMPTracerouteReply{
+				Identifier: pld.Identifier,
+				Sequence:   pld.Sequence,
+				IA:         localAddr.IA,
+				Interface:  0,
+			}
+		default:
+			log.Error("Failed to read packet payload", "type", common.TypeOf(pkt.Payload))
+			continue
+		}
+
+		pkt.Destination, pkt.Source = pkt.Source, pkt.Destination
+		replyPath, err := replypath.Reverser{}.Reverse(pkt.Path)
+		if err != nil {
+			log.Error("Failed to reverse path", "err", err)
+			continue
+		}
+		pkt.Path = replyPath
+		pkt.Payload = reply
+
+		err = pkt.Serialize()
+		if err != nil {
+			log.Error("Failed to serialize SCION packet", "err", err)
+			continue
+		}
+
+		_, err = conn.WriteTo(pkt.Bytes, lastHop)
+		if err != nil {
+			log.Error("Failed to write packet", "err", err)
+			continue
+		}
+	}
+}
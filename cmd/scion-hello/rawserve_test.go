@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+)
+
+func TestRawServerStopsWithContext(t *testing.T) {
+	ia, err := addr.ParseIA("1-ff00:0:110")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := snet.UDPAddr{IA: ia, Host: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 77)}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runServer(ctx, local) }()
+
+	// Give the server time to block in the read.
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("runServer() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+}
+
+// TestRawServerReplies sends a hello packet and SCMP echo and traceroute requests over an
+// empty intra-AS path to the end host port and compares the replies with the packets the
+// server must send back.
+func TestRawServerReplies(t *testing.T) {
+	ia, err := addr.ParseIA("1-ff00:0:110")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ip := netip.MustParseAddr("127.0.0.79")
+	local := snet.UDPAddr{IA: ia, Host: &net.UDPAddr{IP: ip.AsSlice()}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- runServer(ctx, local) }()
+
+	client := snet.SCIONAddress{IA: ia, Host: addr.HostIP(netip.MustParseAddr("127.0.0.2"))}
+	server := snet.SCIONAddress{IA: ia, Host: addr.HostIP(ip)}
+	tests := map[string]struct {
+		request, reply snet.Payload
+	}{
+		"hello": {
+			request: snet.UDPPayload{SrcPort: 40000, DstPort: 8080, Payload: []byte("hello")},
+			reply: snet.UDPPayload{SrcPort: 8080, DstPort: 40000,
+				Payload: []byte("!DLROW ,OLLEh")},
+		},
+		"echo": {
+			request: snet.SCMPEchoRequest{Identifier: 40001, SeqNumber: 7,
+				Payload: []byte("ping")},
+			reply: snet.SCMPEchoReply{Identifier: 40001, SeqNumber: 7, Payload: []byte("ping")},
+		},
+		"traceroute": {
+			request: snet.SCMPTracerouteRequest{Identifier: 40002, Sequence: 3},
+			reply:   snet.SCMPTracerouteReply{Identifier: 40002, Sequence: 3, IA: ia},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := serialize(t, client, server, tc.request)
+			want := serialize(t, server, client, tc.reply)
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip.AsSlice()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			// The server may not listen yet, so the request is sent again until it is
+			// answered.
+			buf := make([]byte, common.SupportedMTU)
+			for attempt := 0; ; attempt++ {
+				_, err := conn.WriteTo(req, &net.UDPAddr{IP: ip.AsSlice(), Port: endHostPort})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+					t.Fatal(err)
+				}
+				n, err := conn.Read(buf)
+				if err == nil {
+					buf = buf[:n]
+					break
+				}
+				if !errors.Is(err, os.ErrDeadlineExceeded) || attempt == 50 {
+					t.Fatalf("no reply: %v", err)
+				}
+			}
+			if !bytes.Equal(buf, want) {
+				t.Errorf("reply = %x, want %x", buf, want)
+			}
+		})
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("runServer() = %v, want %v", err, context.Canceled)
+	}
+}
+
+// serialize returns the packet with payload from src to dst over the empty path.
+func serialize(t *testing.T, src, dst snet.SCIONAddress, payload snet.Payload) []byte {
+	t.Helper()
+	pkt := &snet.Packet{PacketInfo: snet.PacketInfo{
+		Source:      src,
+		Destination: dst,
+		Path:        snetpath.Empty{},
+		Payload:     payload,
+	}}
+	if err := pkt.Serialize(); err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte(nil), pkt.Bytes...)
+}
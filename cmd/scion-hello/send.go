@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+	"github.com/scionproto/scion/private/tracing"
+	integration "github.com/scionproto/scion/tools/integration/integrationlib"
+
+	"github.com/tzaeschke/scion-hello/config"
+	"github.com/tzaeschke/scion-hello/connect"
+	"github.com/tzaeschke/scion-hello/echo"
+	"github.com/tzaeschke/scion-hello/monitor"
+)
+
+// answerTimeout is the time send waits for the answer to a packet.
+const answerTimeout = 5 * time.Second
+
+// sendPad is the number of padding bytes of the hello packets.
+var sendPad uint
+
+func init() {
+	register(&command{
+		name:    "send",
+		summary: "Send two hello packets to a server and print the answers",
+		defaults: config.Defaults{
+			Daemon: "[127.0.0.12]:30255", // from 110-topo
+			Local:  "1-ff00:0:110,127.0.0.2:12345",
+			Remote: "1-ff00:0:112,[::1]:8080",
+		},
+		flags: func(fs *flag.FlagSet, cfg *config.Config) {
+			cfg.RegisterRemoteFlag(fs)
+			registerPathFlags(fs)
+			registerPathChoiceFlags(fs)
+			fs.UintVar(&sendPad, "pad", 0, "pad the packets with this many bytes, to make "+
+				"room for replies longer than the message, e.g. of -responder timestamp")
+		},
+		run: runSend,
+	})
+}
+
+func runSend(ctx context.Context, cfg *config.Config) error {
+	fmt.Println("Starting client ...")
+
+	closeTracer, err := integration.InitTracer("scion-hello-send")
+	if err != nil {
+		return serrors.WrapStr("initializing tracer", err)
+	}
+	defer closeTracer()
+	span, ctx := tracing.CtxWith(ctx, "send")
+	defer span.Finish()
+	span.SetTag("src", cfg.Local.IA)
+	span.SetTag("dst", cfg.Remote.IA)
+
+	fmt.Print("Connecting to daemon: ", cfg.Daemon, " ... ")
+	host, err := connect.Open(ctx, cfg.Daemon, &cfg.Local)
+	if err != nil {
+		return err
+	}
+	defer host.Close()
+	fmt.Println("done")
+
+	dstIA := cfg.Remote.IA
+	srcIA := cfg.Local.IA
+	fmt.Println("src=", srcIA)
+	fmt.Println("dst=", dstIA)
+	srcAddr := cfg.Local.Host
+	dstAddr := cfg.Remote.Host
+	port := uint16(host.LocalAddr().Port)
+	fmt.Printf("Connected as: %v,[%v]:%d \n", srcIA, srcAddr.IP, port)
+
+	// get path
+	fmt.Print("Requesting path ...")
+	pathSpan, pathCtx := tracing.StartSpanFromCtx(ctx, "send.paths")
+	// TODO Refresh:true?
+	paths, err := host.Daemon.Paths(pathCtx, dstIA, srcIA, daemon.PathReqFlags{})
+	tracing.Error(pathSpan, err)
+	pathSpan.Finish()
+	if err != nil {
+		return serrors.WrapStr("requesting paths", err)
+	}
+	fmt.Println("done")
+
+	fmt.Println("Path:")
+	for _, pe := range paths {
+		fmt.Println("   ", pe)
+		fmt.Println("        underlay=", pe.UnderlayNextHop())
+		//fmt.Println("        plane", pe.Dataplane().)
+		fmt.Println("        src=", pe.Source())
+		fmt.Println("        dst=", pe.Destination())
+		meta := pe.Metadata()
+		fmt.Println("        meta=", pe.Metadata())
+		fmt.Println("            Interfaces=", meta.Interfaces)
+		fmt.Println("            Geo=", meta.Geo)
+		fmt.Println("            Bandwidth=", meta.Bandwidth)
+		fmt.Println("            EpicAuths=", meta.EpicAuths)
+		fmt.Println("            Expiry=", meta.Expiry)
+		fmt.Println("            InternalHops=", meta.InternalHops)
+		fmt.Println("            Latency=", meta.Latency)
+		fmt.Println("            LinkType=", meta.LinkType)
+		fmt.Println("            MTU=", meta.MTU)
+		fmt.Println("            Notes=", meta.Notes)
+	}
+
+	if len(paths) == 0 {
+		fmt.Println("  ERROR: No paths found. Try running `./scion.sh topology -c topology/tiny.topo` first.")
+		fmt.Println("         Also make sure that `./scion.sh run` is executed in a (venv).")
+		return serrors.New("no paths found", "src", srcIA, "dst", dstIA)
+	}
+	if paths, err = selectPaths(paths); err != nil {
+		return err
+	}
+	fmt.Printf("Selected path (%v): %v\n", pathSelection.Strategy, paths[0])
+
+	tracker := echo.NewTracker()
+	var m *monitor.Client
+	if cfg.MetricsAddr != "" {
+		m = monitor.NewClientMetrics(&monitor.LabelLimit{Max: cfg.MetricsMaxPaths})
+	}
+	for i := 0; i < 2; i++ {
+		if err := sendAndReceive(ctx, host.Conn, tracker, m, dstIA, dstAddr, srcIA, srcAddr,
+			port, paths); err != nil {
+
+			tracing.Error(span, err)
+			return err
+		}
+	}
+	stats := tracker.Stats()
+	fmt.Printf("Sent: %d, answered: %d, reordered: %d, duplicates: %d, stray: %d\n",
+		stats.Sent, stats.Answered, stats.Reordered, stats.Duplicates, stats.Stray)
+	return nil
+}
+
+// sendAndReceive sends a hello packet and waits for the answer, in a span of its own. The
+// packet carries the span context, so the handling by the server is part of the trace.
+func sendAndReceive(ctx context.Context, conn snet.PacketConn, tracker *echo.Tracker,
+	m *monitor.Client, dstIA addr.IA, dstAddr *net.UDPAddr, srcIA addr.IA, srcAddr *net.UDPAddr,
+	returnPort uint16, paths []snet.Path) error {
+
+	span, ctx := tracing.StartSpanFromCtx(ctx, "send.ping")
+	defer span.Finish()
+	hdr := tracker.Next(time.Now())
+	span.SetTag("seq", hdr.Seq)
+	msg := append(hdr.AppendTo(nil), "Hello scion"...)
+	if sendPad > 0 {
+		var err error
+		if msg, err = echo.WithPadding(int(sendPad), msg); err != nil {
+			return err
+		}
+	}
+	payload, err := echo.WithTrace(tracing.IDFromCtx(ctx), msg)
+	if err != nil {
+		return err
+	}
+	// send packet
+	err = sendPacket(conn, dstIA, dstAddr, srcIA, srcAddr, returnPort, paths, payload)
+	if err != nil {
+		tracing.Error(span, err)
+		return err
+	}
+	// receive answer
+	fingerprint := echo.DataplaneFingerprint(paths[0].Dataplane())
+	if err := receiveAnswer(conn, tracker, hdr.Seq, func(r echo.Reply) {
+		m.Observe(dstIA, fingerprint, r)
+	}); err != nil {
+		tracing.Error(span, err)
+		return err
+	}
+	return nil
+}
+
+func sendPacket(conn snet.PacketConn, dstIA addr.IA, dstAddr *net.UDPAddr, srcIA addr.IA,
+	srcAddr *net.UDPAddr, returnPort uint16, paths []snet.Path, payload []byte) error {
+
+	fmt.Printf("Source: %v,%v\n", srcIA, srcAddr)
+	fmt.Printf("Destination: %v,%v\n", dstIA, dstAddr)
+	fmt.Print("Creating packet ... ")
+	remoteHostIP, ok := netip.AddrFromSlice(dstAddr.IP)
+	if !ok {
+		return serrors.New("invalid remote host IP", "ip", dstAddr.IP)
+	}
+	localHostIP, ok := netip.AddrFromSlice(srcAddr.IP)
+	if !ok {
+		return serrors.New("invalid local host IP", "ip", srcAddr.IP)
+	}
+	path := paths[0]
+	pkt := &snet.Packet{
+		PacketInfo: snet.PacketInfo{
+			Destination: snet.SCIONAddress{
+				IA:   dstIA,
+				Host: addr.HostIP(remoteHostIP),
+			},
+			Source: snet.SCIONAddress{
+				IA:   srcIA,
+				Host: addr.HostIP(localHostIP),
+			},
+			Path: path.Dataplane(),
+			Payload: snet.UDPPayload{
+				SrcPort: returnPort,
+				DstPort: uint16(dstAddr.Port),
+				Payload: payload,
+			},
+		},
+	}
+	fmt.Println("done")
+
+	fmt.Printf("Sending packet to first hop: %v  ... ", path.UnderlayNextHop())
+	if err := conn.WriteTo(pkt, path.UnderlayNextHop()); err != nil {
+		return serrors.WrapStr("sending packet", err)
+	}
+	fmt.Println("done")
+	return nil
+}
+
+// receiveAnswer waits for the answer to request seq. Other replies, e.g. duplicates or late
+// answers to earlier requests, are reported and skipped. observe is called for every reply.
+func receiveAnswer(conn snet.PacketConn, tracker *echo.Tracker, seq uint64,
+	observe func(echo.Reply)) error {
+
+	if err := conn.SetReadDeadline(time.Now().Add(answerTimeout)); err != nil {
+		return serrors.WrapStr("setting read deadline", err)
+	}
+	fmt.Print("Waiting ... ")
+	for {
+		var p snet.Packet
+		var ov net.UDPAddr
+		if err := conn.ReadFrom(&p, &ov); err != nil {
+			tracker.Forget(seq)
+			return serrors.WrapStr("reading packet", err, "seq", seq)
+		}
+		now := time.Now()
+		fmt.Println("received answer")
+
+		udp, ok := p.Payload.(snet.UDPPayload)
+		if !ok {
+			return serrors.New("unexpected payload", "type", common.TypeOf(p.Payload))
+		}
+
+		// Servers answering traced packets prefix the reply with the span context as well.
+		_, msg, _ := echo.SplitTrace(udp.Payload)
+		hdr, msg, err := echo.ParseHeader(msg)
+		if err != nil {
+			// Servers that do not know the header cannot be matched, e.g. raw-serve.
+			fmt.Printf("Received message: \"%s\" from %v:%v (%v)\n",
+				string(msg), ov.IP, udp.SrcPort, err)
+			return nil
+		}
+		reply := tracker.Match(hdr, now)
+		observe(reply)
+		fmt.Printf("Received message: \"%s\" from %v:%v seq=%d rtt=%v (%v)\n",
+			string(msg), ov.IP, udp.SrcPort, reply.Seq, reply.RTT, reply.Kind)
+		if reply.Seq == seq && (reply.Kind == echo.InOrder || reply.Kind == echo.Reordered) {
+			return nil
+		}
+	}
+}
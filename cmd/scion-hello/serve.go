@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+	integration "github.com/scionproto/scion/tools/integration/integrationlib"
+
+	"github.com/tzaeschke/scion-hello/config"
+	"github.com/tzaeschke/scion-hello/connect"
+	"github.com/tzaeschke/scion-hello/echo"
+	"github.com/tzaeschke/scion-hello/monitor"
+	"github.com/tzaeschke/scion-hello/pathsel"
+	"github.com/tzaeschke/scion-hello/replypath"
+)
+
+// serveFlags are the flags of the serve command.
+type serveFlags struct {
+	workers      int
+	drainTimeout time.Duration
+	limits       echo.Limits
+	responders   responderFlags
+	adminAddr    string
+	sessionIdle  time.Duration
+	epicReplies  replypath.EPICPolicy
+	replyPaths   replyPathFlag
+}
+
+var serveCfg = serveFlags{
+	drainTimeout: echo.DefaultDrainTimeout,
+	sessionIdle:  echo.DefaultSessionIdle,
+}
+
+func init() {
+	register(&command{
+		name:    "serve",
+		summary: "Run the hello server, answering every UDP payload with the -responder reply",
+		defaults: config.Defaults{
+			Daemon: "[fd00:f00d:cafe::7f00:b]:30255", // from 112 topo
+			Local:  "1-ff00:0:112,[::1]:8080",
+		},
+		flags: func(fs *flag.FlagSet, cfg *config.Config) {
+			cfg.RegisterListenFlag(fs)
+			fs.IntVar(&serveCfg.workers, "workers", 0,
+				"number of goroutines answering packets (default number of CPUs)")
+			fs.DurationVar(&serveCfg.drainTimeout, "drain-timeout", serveCfg.drainTimeout,
+				"time to answer pending packets on shutdown")
+			fs.Float64Var(&serveCfg.limits.PerIA.PerSecond, "limit.ia", 0,
+				"packets per second answered per source ISD-AS (default unlimited)")
+			fs.Float64Var(&serveCfg.limits.PerHost.PerSecond, "limit.host", 0,
+				"packets per second answered per source host (default unlimited)")
+			fs.Var((*iaRanges)(&serveCfg.limits.Allow), "allow",
+				"ISD-AS range to answer, e.g. 1-ff00:0:110, 1-0 or 1-ff00:0:100-ff00:0:1ff "+
+					"(repeatable, default all)")
+			fs.Var((*iaRanges)(&serveCfg.limits.Deny), "deny",
+				"ISD-AS range to drop, takes precedence over -allow (repeatable)")
+			fs.Var(&serveCfg.responders, "responder",
+				"reply mode [ADDRESS=]NAME[:ARG], e.g. reverse or "+
+					"1-ff00:0:112,[::1]:8080=prefix:Re: (repeatable, default echo; one of "+
+					strings.Join(echo.Responders(), ", ")+")")
+			fs.StringVar(&serveCfg.adminAddr, "admin-addr", "",
+				"local address to serve the client sessions as JSON on /sessions, "+
+					"e.g. 127.0.0.1:9101 (default off)")
+			fs.DurationVar(&serveCfg.sessionIdle, "session-idle", serveCfg.sessionIdle,
+				"time after which idle client sessions expire")
+			fs.Var(&serveCfg.epicReplies, "epic-replies",
+				"answer requests over EPIC paths with one of "+
+					strings.Join(replypath.EPICPolicies(), ", ")+
+					" (scion replies over the reversed SCION path)")
+			fs.Var(&serveCfg.replyPaths, "reply-paths",
+				"reply over the reversed request path (reverse) or over the path from the daemon "+
+					"that ranks best by one of "+strings.Join(pathsel.Strategies(), ", "))
+		},
+		run: runServe,
+	})
+}
+
+// Without dispatcher
+func runServe(ctx context.Context, cfg *config.Config) error {
+	fmt.Println("Starting server ...")
+
+	closeTracer, err := integration.InitTracer("scion-hello-serve")
+	if err != nil {
+		return serrors.WrapStr("initializing tracer", err)
+	}
+	defer closeTracer()
+
+	fmt.Print("Connecting to daemon: ", cfg.Daemon, " ... ")
+	host, err := connect.Open(ctx, cfg.Daemon, &cfg.Local)
+	if err != nil {
+		return err
+	}
+	defer host.Close()
+	fmt.Println("done")
+
+	localAddr := host.LocalAddr()
+	fmt.Printf("Connected as: %v,[%v]:%d \n", host.IA, localAddr.IP, localAddr.Port)
+
+	if err := serveCfg.responders.check(cfg); err != nil {
+		return err
+	}
+	listeners := []echo.Listener{{
+		Conn:      host.Conn,
+		Responder: serveCfg.responders.lookup(&cfg.Local),
+	}}
+	connector := connect.NewConnector(host.Daemon)
+	for i := range cfg.Listen {
+		l := &cfg.Listen[i]
+		conn, err := connector.OpenUDP(l.Host)
+		if err != nil {
+			return serrors.WrapStr("opening packet connection", err, "listen", l)
+		}
+		defer conn.Close()
+		localAddr := conn.LocalAddr().(*net.UDPAddr)
+		fmt.Printf("Listening on: %v,[%v]:%d \n", host.IA, localAddr.IP, localAddr.Port)
+		listeners = append(listeners, echo.Listener{
+			Conn:      conn,
+			Responder: serveCfg.responders.lookup(l),
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	srv := &echo.Server{
+		Workers:      serveCfg.workers,
+		DrainTimeout: serveCfg.drainTimeout,
+		Limits:       serveCfg.limits,
+		Responder:    serveCfg.responders.def,
+		ReplyPaths:   replypath.Reverser{EPIC: serveCfg.epicReplies},
+	}
+	if serveCfg.replyPaths.lookup {
+		srv.ReplyPaths = &replypath.Lookup{
+			Querier:  daemon.Querier{Connector: host.Daemon, IA: host.IA},
+			Strategy: serveCfg.replyPaths.strategy,
+			Reverser: replypath.Reverser{EPIC: serveCfg.epicReplies},
+		}
+	}
+	if cfg.MetricsAddr != "" {
+		srv.Metrics = monitor.NewServerMetrics(&monitor.LabelLimit{Max: cfg.MetricsMaxPaths})
+	}
+	if serveCfg.adminAddr != "" {
+		srv.Sessions = &echo.Sessions{IdleTimeout: serveCfg.sessionIdle}
+		mux := http.NewServeMux()
+		mux.Handle("/sessions", srv.Sessions)
+		go func() {
+			defer log.HandlePanic()
+			err := monitor.ServeHandler(ctx, serveCfg.adminAddr, mux)
+			if err != nil && ctx.Err() == nil {
+				log.Error("Serving admin endpoint failed", "err", err)
+			}
+		}()
+	}
+	err = srv.ServeListeners(ctx, listeners...)
+	stats := srv.Stats()
+	fmt.Printf("Received: %d, replied: %d, failed: %d, dropped: %d "+
+		"(denied: %d, rate limited: %d, oversized: %d)\n",
+		stats.Received, stats.Replied, stats.Failed, stats.Dropped(),
+		stats.Denied, stats.Limited, stats.Oversized)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// iaRanges is a repeatable flag of ISD-AS ranges.
+type iaRanges []echo.IARange
+
+func (r *iaRanges) String() string {
+	s := make([]string, 0, len(*r))
+	for _, ia := range *r {
+		s = append(s, ia.String())
+	}
+	return strings.Join(s, " ")
+}
+
+func (r *iaRanges) Set(s string) error {
+	ia, err := echo.ParseIARange(s)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, ia)
+	return nil
+}
+
+// replyPathFlag is the -reply-paths flag.
+type replyPathFlag struct {
+	// lookup is set if the replies take a path from the daemon chosen by strategy.
+	lookup   bool
+	strategy pathsel.Strategy
+}
+
+func (f *replyPathFlag) String() string {
+	if !f.lookup {
+		return "reverse"
+	}
+	return f.strategy.String()
+}
+
+func (f *replyPathFlag) Set(s string) error {
+	if s == "reverse" {
+		*f = replyPathFlag{}
+		return nil
+	}
+	var strategy pathsel.Strategy
+	if err := strategy.Set(s); err != nil {
+		return err
+	}
+	*f = replyPathFlag{lookup: true, strategy: strategy}
+	return nil
+}
+
+// responderFlags is the repeatable -responder flag.
+type responderFlags struct {
+	// def is the responder of listeners without their own, nil for the default.
+	def     echo.Responder
+	specs   []string
+	byLocal []listenerResponder
+}
+
+// listenerResponder is the responder of a listener.
+type listenerResponder struct {
+	local     snet.UDPAddr
+	responder echo.Responder
+}
+
+func (f *responderFlags) String() string {
+	return strings.Join(f.specs, " ")
+}
+
+func (f *responderFlags) Set(s string) error {
+	// The spec may contain "=" as well, so only split if the prefix is an address.
+	if local, spec, ok := strings.Cut(s, "="); ok {
+		var a snet.UDPAddr
+		if err := a.Set(local); err == nil {
+			r, err := echo.NewResponder(spec)
+			if err != nil {
+				return err
+			}
+			f.byLocal = append(f.byLocal, listenerResponder{local: a, responder: r})
+			f.specs = append(f.specs, s)
+			return nil
+		}
+	}
+	r, err := echo.NewResponder(s)
+	if err != nil {
+		return err
+	}
+	f.def = r
+	f.specs = append(f.specs, s)
+	return nil
+}
+
+// check verifies that all listener specific responders belong to a listener.
+func (f *responderFlags) check(cfg *config.Config) error {
+	for _, lr := range f.byLocal {
+		found := sameUDPAddr(&lr.local, &cfg.Local)
+		for i := range cfg.Listen {
+			found = found || sameUDPAddr(&lr.local, &cfg.Listen[i])
+		}
+		if !found {
+			return serrors.New("responder for unknown listener", "listener", lr.local.String())
+		}
+	}
+	return nil
+}
+
+// lookup returns the responder of the listener on local, nil if it has none.
+func (f *responderFlags) lookup(local *snet.UDPAddr) echo.Responder {
+	for _, lr := range f.byLocal {
+		if sameUDPAddr(&lr.local, local) {
+			return lr.responder
+		}
+	}
+	return nil
+}
+
+func sameUDPAddr(a, b *snet.UDPAddr) bool {
+	return a.IA.Equal(b.IA) && a.Host.IP.Equal(b.Host.IP) && a.Host.Port == b.Host.Port
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/google/gopacket"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/slayers"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"github.com/tzaeschke/scion-hello/config"
+)
+
+// shimPort is the port the shim listens on. Packets for the end host port are redirected to it
+// with iptables, see HINTS.txt.
+const shimPort = 40041
+
+// The pause after a failed read of the shim and raw-serve doubles with every consecutive
+// failure, from minReadBackoff up to maxReadBackoff, so that a persistent error does not make
+// the reader spin.
+const (
+	minReadBackoff = time.Millisecond
+	maxReadBackoff = time.Second
+)
+
+func init() {
+	register(&command{
+		name:    "shim",
+		summary: "Run a minimal dispatcher that forwards SCION packets to the UDP destination port",
+		run: func(ctx context.Context, cfg *config.Config) error {
+			return runFwd(ctx, cfg.Local)
+		},
+	})
+}
+
+// runFwd is a minimal "Dispatcher" implementation. It forwards packets until ctx is done and
+// then returns ctx.Err().
+func runFwd(ctx context.Context, localAddr snet.UDPAddr) error {
+	listen := &net.UDPAddr{
+		IP:   localAddr.Host.IP,
+		Port: shimPort,
+		Zone: localAddr.Host.Zone,
+	}
+
+	log.Info("Listening", "isd_as", localAddr.IA, "local", listen, "svc", addr.SvcNone)
+
+	conn, err := net.ListenUDP("udp", listen)
+	if err != nil {
+		return serrors.WrapStr("listening on UDP connection", err)
+	}
+	defer conn.Close()
+	// Unblock the pending read once ctx is done.
+	stop := context.AfterFunc(ctx, func() {
+		if err := conn.SetReadDeadline(time.Now()); err != nil {
+			log.Error("Failed to interrupt read", "err", err)
+		}
+	})
+	defer stop()
+
+	buf := make([]byte, 9216-20-8 /* MTU supported by SCION */)
+
+	var (
+		scionLayer slayers.SCION
+		hbhLayer   slayers.HopByHopExtnSkipper
+		e2eLayer   slayers.EndToEndExtn
+		udpLayer   slayers.UDP
+		scmpLayer  slayers.SCMP
+	)
+	scionLayer.RecyclePaths()
+	udpLayer.SetNetworkLayerForChecksum(&scionLayer)
+	scmpLayer.SetNetworkLayerForChecksum(&scionLayer)
+	parser := gopacket.NewDecodingLayerParser(
+		slayers.LayerTypeSCION, &scionLayer, &hbhLayer, &e2eLayer, &udpLayer, &scmpLayer,
+	)
+	parser.IgnoreUnsupported = true
+	decoded := make([]gopacket.LayerType, 4)
+	buffer := gopacket.NewSerializeBuffer()
+	options := gopacket.SerializeOptions{
+		ComputeChecksums: true,
+		FixLengths:       true,
+	}
+
+	var backoff time.Duration
+	for {
+		buf = buf[:cap(buf)]
+
+		n, _, err := conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return serrors.WrapStr("reading packet", err)
+			}
+			if err := retryRead(ctx, &backoff, err); err != nil {
+				return err
+			}
+			continue
+		}
+		backoff = 0
+		buf = buf[:n]
+
+		err = parser.DecodeLayers(buf, &decoded)
+		if err != nil {
+			log.Error("Failed to decode packet", "err", err)
+			continue
+		}
+		validType := len(decoded) >= 2 &&
+			decoded[len(decoded)-1] == slayers.LayerTypeSCIONUDP
+		if !validType {
+			log.Error("Failed to decode packet: unexpected type or structure")
+			continue
+		}
+
+		if int(udpLayer.DstPort) == shimPort {
+			continue
+		}
+		dstAddr, ok := netip.AddrFromSlice(scionLayer.RawDstAddr)
+		if !ok {
+			log.Error("Unexpected destination address", "raw", scionLayer.RawDstAddr)
+			continue
+		}
+		dstAddrPort := netip.AddrPortFrom(dstAddr, udpLayer.DstPort)
+
+		if err := serializeUDP(buffer, options, &scionLayer, &e2eLayer, &udpLayer); err != nil {
+			log.Error("Failed to serialize packet", "err", err)
+			continue
+		}
+
+		m, err := conn.WriteToUDPAddrPort(buffer.Bytes(), dstAddrPort)
+		if err != nil || m != len(buffer.Bytes()) {
+			log.Error("Failed to write packet", "err", err, "written", m)
+			continue
+		}
+	}
+}
+
+// retryRead logs the failed read err and waits before the next read. backoff is the previous
+// pause, it is doubled. retryRead returns ctx.Err() if ctx is done before.
+func retryRead(ctx context.Context, backoff *time.Duration, err error) error {
+	*backoff = min(max(2**backoff, minReadBackoff), maxReadBackoff)
+	log.Error("Failed to read packet", "err", err, "retry_in", *backoff)
+	t := time.NewTimer(*backoff)
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		t.Stop()
+		return ctx.Err()
+	}
+}
+
+// serializeUDP serializes the decoded layers of a SCION/UDP packet into buffer.
+func serializeUDP(buffer gopacket.SerializeBuffer, options gopacket.SerializeOptions,
+	scionLayer *slayers.SCION, e2eLayer *slayers.EndToEndExtn, udpLayer *slayers.UDP) error {
+
+	payload := gopacket.Payload(udpLayer.Payload)
+
+	if err := buffer.Clear(); err != nil {
+		return err
+	}
+	if err := payload.SerializeTo(buffer, options); err != nil {
+		return err
+	}
+	buffer.PushLayer(payload.LayerType())
+
+	if err := udpLayer.SerializeTo(buffer, options); err != nil {
+		return err
+	}
+	buffer.PushLayer(udpLayer.LayerType())
+
+	if scionLayer.NextHdr == slayers.End2EndClass {
+		if err := e2eLayer.SerializeTo(buffer, options); err != nil {
+			return err
+		}
+		buffer.PushLayer(e2eLayer.LayerType())
+	}
+
+	if err := scionLayer.SerializeTo(buffer, options); err != nil {
+		return err
+	}
+	buffer.PushLayer(scionLayer.LayerType())
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+)
+
+func TestShimForwardsAndStopsWithContext(t *testing.T) {
+	ia, err := addr.ParseIA("1-ff00:0:110")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ip := netip.MustParseAddr("127.0.0.78")
+	local := snet.UDPAddr{IA: ia, Host: &net.UDPAddr{IP: ip.AsSlice()}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- runFwd(ctx, local) }()
+
+	dst, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip.AsSlice()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	pkt := &snet.Packet{PacketInfo: snet.PacketInfo{
+		Source:      snet.SCIONAddress{IA: ia, Host: addr.HostIP(ip)},
+		Destination: snet.SCIONAddress{IA: ia, Host: addr.HostIP(ip)},
+		Path:        snetpath.Empty{},
+		Payload: snet.UDPPayload{
+			SrcPort: 40000,
+			DstPort: uint16(dst.LocalAddr().(*net.UDPAddr).Port),
+			Payload: []byte("hello"),
+		},
+	}}
+	if err := pkt.Serialize(); err != nil {
+		t.Fatal(err)
+	}
+	// The client socket is not connected, so that it ignores ICMP errors while the shim does
+	// not listen yet.
+	src, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip.AsSlice()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	shim := &net.UDPAddr{IP: ip.AsSlice(), Port: shimPort}
+	// The shim may not listen yet, so the packet is sent again until it arrives.
+	var fwd snet.Packet
+	fwd.Prepare()
+	for attempt := 0; ; attempt++ {
+		if _, err := src.WriteTo(pkt.Bytes, shim); err != nil {
+			t.Fatal(err)
+		}
+		if err := dst.SetReadDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+		n, err := dst.Read(fwd.Bytes)
+		if err == nil {
+			fwd.Bytes = fwd.Bytes[:n]
+			break
+		}
+		if !errors.Is(err, os.ErrDeadlineExceeded) || attempt == 50 {
+			t.Fatalf("no forwarded packet: %v", err)
+		}
+	}
+	if err := fwd.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if udp, ok := fwd.Payload.(snet.UDPPayload); !ok || string(udp.Payload) != "hello" {
+		t.Errorf("forwarded payload = %v, want %q", fwd.Payload, "hello")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("runFwd() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shim did not stop")
+	}
+}
@@ -0,0 +1,323 @@
+// Package config provides the global command-line flags and the optional TOML configuration
+// file of the scion-hello commands.
+//
+// A configuration file looks like this:
+//
+//	daemon       = "[127.0.0.12]:30255"
+//	local        = "1-ff00:0:110,127.0.0.2:12345"
+//	remote       = "1-ff00:0:112,[::1]:8080"
+//	listen       = ["1-ff00:0:110,[::1]:12345"]
+//	log_level    = "debug"
+//	metrics_addr = "127.0.0.1:9100"
+//
+// Instead of the daemon address, the gen/ directory of a local topology and the local ISD-AS
+// can be given, e.g. `-gen ./gen -ia 1-ff00:0:110`. The daemon address is then discovered
+// from gen/ASff00_0_110/sd.toml.
+//
+// Flags that are given explicitly on the command line take precedence over the file.
+package config
+
+import (
+	"flag"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"github.com/tzaeschke/scion-hello/discovery"
+)
+
+// Config is the configuration shared by all commands.
+type Config struct {
+	// Daemon is the address of the SCION daemon, e.g. "[127.0.0.12]:30255".
+	Daemon string
+	// Local is the local SCION address, e.g. "1-ff00:0:110,127.0.0.2:12345".
+	Local snet.UDPAddr
+	// Remote is the SCION address of the server. It is only used by client commands.
+	Remote snet.UDPAddr
+	// Listen are additional local addresses of a server, e.g. to serve both IPv4 and IPv6.
+	// They must be in the ISD-AS of Local.
+	Listen []snet.UDPAddr
+	// Gen is the gen/ directory of a local topology. If set, the daemon address is
+	// discovered from it.
+	Gen string
+	// IA is the local ISD-AS in the gen/ directory. It defaults to the ISD-AS of Local.
+	IA addr.IA
+	// LogLevel is the console log level: debug|info|error.
+	LogLevel string
+	// MetricsAddr is the address of the HTTP listener serving /metrics. If empty, the
+	// metrics are not served.
+	MetricsAddr string
+	// MetricsMaxPaths bounds the number of remote ISD-AS and path label pairs of the
+	// metrics, 0 means unbounded.
+	MetricsMaxPaths int
+	// Discovered is the AS found in Gen, if any.
+	Discovered *discovery.AS
+
+	file           string
+	withRemote     bool
+	remoteOptional bool
+}
+
+// Defaults are the values a command uses for settings that are not configured.
+type Defaults struct {
+	Daemon string
+	Local  string
+	Remote string
+}
+
+// file is the TOML representation of Config.
+type file struct {
+	Daemon   string   `toml:"daemon"`
+	Local    string   `toml:"local"`
+	Remote   string   `toml:"remote"`
+	Listen   []string `toml:"listen"`
+	Gen      string   `toml:"gen"`
+	IA       string   `toml:"ia"`
+	LogLevel string   `toml:"log_level"`
+
+	MetricsAddr     string `toml:"metrics_addr"`
+	MetricsMaxPaths int    `toml:"metrics_max_paths"`
+}
+
+// RegisterFlags registers the global flags on fs.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.file, "config", "", "TOML configuration file")
+	fs.StringVar(&c.Daemon, "daemon", c.Daemon, "SCION daemon address")
+	fs.Var(&c.Local, "local", "Local address, e.g. 1-ff00:0:110,127.0.0.2:12345")
+	fs.StringVar(&c.Gen, "gen", c.Gen, "gen/ directory to discover the daemon address from")
+	fs.Var(&c.IA, "ia", "Local ISD-AS in the gen/ directory (default: ISD-AS of -local)")
+	fs.StringVar(&c.LogLevel, "log.level", log.DefaultConsoleLevel,
+		"Console logging level: debug|info|error")
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", c.MetricsAddr,
+		"Address to serve Prometheus metrics on /metrics, e.g. 127.0.0.1:9100 (default: off)")
+	fs.IntVar(&c.MetricsMaxPaths, "metrics-max-paths", c.MetricsMaxPaths,
+		"Bound the metric labels to this many remote ISD-AS and path pairs, further pairs "+
+			"are labeled \"other\" (default: unbounded)")
+}
+
+// RegisterRemoteFlag registers the -remote flag on fs, typically the flag set of a client
+// command. Resolve then requires a remote address.
+func (c *Config) RegisterRemoteFlag(fs *flag.FlagSet) {
+	c.withRemote = true
+	fs.Var(&c.Remote, "remote", "Remote address, e.g. 1-ff00:0:112,[::1]:8080")
+}
+
+// RegisterOptionalRemoteFlag is like RegisterRemoteFlag, but Resolve only validates the remote
+// address if one is given, e.g. for commands that are a client in only some modes.
+func (c *Config) RegisterOptionalRemoteFlag(fs *flag.FlagSet) {
+	c.RegisterRemoteFlag(fs)
+	c.remoteOptional = true
+}
+
+// RegisterListenFlag registers the repeatable -listen flag on fs, typically the flag set of a
+// server command.
+func (c *Config) RegisterListenFlag(fs *flag.FlagSet) {
+	fs.Var(&udpAddrs{addrs: &c.Listen}, "listen",
+		"Additional local address, e.g. 1-ff00:0:110,[::1]:12345 (repeatable)")
+}
+
+// udpAddrs is a repeatable flag of SCION addresses. The first address given on the command
+// line replaces the addresses from the configuration file.
+type udpAddrs struct {
+	addrs *[]snet.UDPAddr
+	set   bool
+}
+
+func (l *udpAddrs) String() string {
+	if l.addrs == nil {
+		return ""
+	}
+	s := make([]string, 0, len(*l.addrs))
+	for _, a := range *l.addrs {
+		s = append(s, a.String())
+	}
+	return strings.Join(s, " ")
+}
+
+func (l *udpAddrs) Set(s string) error {
+	var a snet.UDPAddr
+	if err := a.Set(s); err != nil {
+		return err
+	}
+	if !l.set {
+		*l.addrs = nil
+		l.set = true
+	}
+	*l.addrs = append(*l.addrs, a)
+	return nil
+}
+
+// Load applies the configuration file given by -config, if any. fs must already have been
+// parsed with args; it is parsed again so that explicit flags override the file.
+func (c *Config) Load(fs *flag.FlagSet, args []string) error {
+	if c.file == "" {
+		return nil
+	}
+	if err := c.loadFile(c.file); err != nil {
+		return err
+	}
+	// Parse again so that explicit flags override the file.
+	return fs.Parse(args)
+}
+
+// Resolve fills in unset values from d, discovers the daemon address in the gen/ directory
+// and validates the result. A daemon address that was configured explicitly takes precedence
+// over the discovered one. A local address that was configured explicitly must be in the
+// discovered ISD-AS.
+func (c *Config) Resolve(d Defaults) error {
+	localSet := c.Local.Host != nil
+	if !localSet && d.Local != "" {
+		if err := c.Local.Set(d.Local); err != nil {
+			return serrors.WrapStr("parsing default local address", err, "local", d.Local)
+		}
+	}
+	if c.withRemote && c.Remote.Host == nil && d.Remote != "" {
+		if err := c.Remote.Set(d.Remote); err != nil {
+			return serrors.WrapStr("parsing default remote address", err, "remote", d.Remote)
+		}
+	}
+	if c.Gen != "" {
+		if err := c.discover(localSet); err != nil {
+			return err
+		}
+	}
+	if c.Daemon == "" {
+		c.Daemon = d.Daemon
+	}
+	return c.Validate()
+}
+
+// discover looks up the local AS in the gen/ directory. If localSet, the local address was
+// configured and its ISD-AS must be the discovered one; otherwise it is replaced.
+func (c *Config) discover(localSet bool) error {
+	ia := c.IA
+	if ia.IsZero() {
+		ia = c.Local.IA
+	}
+	as, err := discovery.Load(c.Gen, ia)
+	if err != nil {
+		return serrors.WrapStr("discovering local AS", err, "gen", c.Gen)
+	}
+	if localSet && !c.Local.IA.Equal(as.IA) {
+		return serrors.New("local address not in discovered ISD-AS", "local", c.Local.IA,
+			"discovered", as.IA, "gen", c.Gen)
+	}
+	c.Discovered = as
+	if c.Daemon == "" {
+		c.Daemon = as.Daemon
+	}
+	c.Local.IA = as.IA
+	return nil
+}
+
+func (c *Config) loadFile(path string) error {
+	r, err := os.Open(path)
+	if err != nil {
+		return serrors.WrapStr("opening config file", err)
+	}
+	defer r.Close()
+	var f file
+	if err := toml.NewDecoder(r).Strict(true).Decode(&f); err != nil {
+		return serrors.WrapStr("parsing config file", err, "file", path)
+	}
+	if f.Daemon != "" {
+		c.Daemon = f.Daemon
+	}
+	if f.Local != "" {
+		if err := c.Local.Set(f.Local); err != nil {
+			return serrors.WrapStr("parsing local address", err, "file", path,
+				"local", f.Local)
+		}
+	}
+	if f.Remote != "" {
+		if err := c.Remote.Set(f.Remote); err != nil {
+			return serrors.WrapStr("parsing remote address", err, "file", path,
+				"remote", f.Remote)
+		}
+	}
+	for _, l := range f.Listen {
+		var a snet.UDPAddr
+		if err := a.Set(l); err != nil {
+			return serrors.WrapStr("parsing listen address", err, "file", path, "listen", l)
+		}
+		c.Listen = append(c.Listen, a)
+	}
+	if f.Gen != "" {
+		c.Gen = f.Gen
+	}
+	if f.IA != "" {
+		if err := c.IA.Set(f.IA); err != nil {
+			return serrors.WrapStr("parsing ISD-AS", err, "file", path, "ia", f.IA)
+		}
+	}
+	if f.LogLevel != "" {
+		c.LogLevel = f.LogLevel
+	}
+	if f.MetricsAddr != "" {
+		c.MetricsAddr = f.MetricsAddr
+	}
+	if f.MetricsMaxPaths != 0 {
+		c.MetricsMaxPaths = f.MetricsMaxPaths
+	}
+	return nil
+}
+
+// Validate checks that the configuration is complete. The daemon address is optional, as not
+// all commands need a daemon.
+func (c *Config) Validate() error {
+	if c.Daemon != "" {
+		if _, _, err := net.SplitHostPort(c.Daemon); err != nil {
+			return serrors.WrapStr("invalid daemon address", err, "daemon", c.Daemon)
+		}
+	}
+	if err := validateUDPAddr("local", &c.Local); err != nil {
+		return err
+	}
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			return serrors.WrapStr("invalid metrics address", err, "metrics", c.MetricsAddr)
+		}
+	}
+	if c.MetricsMaxPaths < 0 {
+		return serrors.New("negative metrics path bound", "max_paths", c.MetricsMaxPaths)
+	}
+	for i := range c.Listen {
+		a := &c.Listen[i]
+		if err := validateUDPAddr("listen", a); err != nil {
+			return err
+		}
+		if !a.IA.Equal(c.Local.IA) {
+			return serrors.New("listen address not in local ISD-AS", "listen", a.String(),
+				"local", c.Local.IA)
+		}
+	}
+	if !c.withRemote || c.remoteOptional && c.Remote.Host == nil {
+		return nil
+	}
+	if err := validateUDPAddr("remote", &c.Remote); err != nil {
+		return err
+	}
+	if c.Remote.Host.Port == 0 {
+		return serrors.New("missing remote port", "remote", c.Remote.String())
+	}
+	return nil
+}
+
+func validateUDPAddr(name string, a *snet.UDPAddr) error {
+	if a.Host == nil {
+		return serrors.New("missing "+name+" address", name, a.String())
+	}
+	if a.IA.IsZero() {
+		return serrors.New("missing "+name+" ISD-AS", name, a.String())
+	}
+	if a.Host.IP == nil || a.Host.IP.IsUnspecified() {
+		return serrors.New("missing "+name+" IP", name, a.String())
+	}
+	return nil
+}
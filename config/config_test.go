@@ -0,0 +1,249 @@
+package config_test
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/scionproto/scion/pkg/snet"
+
+	"github.com/tzaeschke/scion-hello/config"
+)
+
+// parse parses the global args and the command args, like main, into a config with a -remote
+// flag if remote is set.
+func parse(t *testing.T, args []string, remote bool, cmdArgs ...string) *config.Config {
+	t.Helper()
+	var cfg config.Config
+	global := flag.NewFlagSet("scion-hello", flag.ContinueOnError)
+	cfg.RegisterFlags(global)
+	if err := global.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Load(global, args); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("cmd", flag.ContinueOnError)
+	if remote {
+		cfg.RegisterRemoteFlag(fs)
+	}
+	cfg.RegisterListenFlag(fs)
+	if err := fs.Parse(cmdArgs); err != nil {
+		t.Fatal(err)
+	}
+	return &cfg
+}
+
+func mustUDPAddr(s string) snet.UDPAddr {
+	a, err := snet.ParseUDPAddr(s)
+	if err != nil {
+		panic(err)
+	}
+	return *a
+}
+
+func TestLoadFlagsOverrideFile(t *testing.T) {
+	cfg := parse(t, []string{"-config", "testdata/hello.toml", "-daemon", "127.0.0.1:30255",
+		"-log.level", "error"}, true, "-remote", "1-ff00:0:113,127.0.0.3:9090")
+	if err := cfg.Resolve(config.Defaults{}); err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		name      string
+		got, want string
+	}{
+		{"daemon", cfg.Daemon, "127.0.0.1:30255"},
+		{"log level", cfg.LogLevel, "error"},
+		{"local", cfg.Local.String(), "1-ff00:0:110,127.0.0.2:12345"},
+		{"remote", cfg.Remote.String(), "1-ff00:0:113,127.0.0.3:9090"},
+		{"metrics address", cfg.MetricsAddr, "127.0.0.1:9100"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %q, want %q", c.name, c.got, c.want)
+		}
+	}
+	if len(cfg.Listen) != 1 || cfg.Listen[0].String() != "1-ff00:0:110,[::1]:12345" {
+		t.Errorf("listen = %v, want the address of the file", cfg.Listen)
+	}
+}
+
+func TestLoadListenFlagReplacesFile(t *testing.T) {
+	cfg := parse(t, []string{"-config", "testdata/hello.toml"}, false,
+		"-listen", "1-ff00:0:110,127.0.0.3:1", "-listen", "1-ff00:0:110,127.0.0.4:1")
+	if len(cfg.Listen) != 2 || cfg.Listen[0].String() != "1-ff00:0:110,127.0.0.3:1" {
+		t.Errorf("listen = %v, want the addresses of the flags only", cfg.Listen)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	defaults := config.Defaults{
+		Daemon: "[127.0.0.12]:30255",
+		Local:  "1-ff00:0:110,127.0.0.2:0",
+		Remote: "1-ff00:0:112,[::1]:8080",
+	}
+	tests := map[string]struct {
+		args []string
+		// wantDaemon and wantLocal are the resolved addresses, empty if Resolve fails.
+		wantDaemon, wantLocal string
+	}{
+		"defaults": {
+			wantDaemon: "[127.0.0.12]:30255",
+			wantLocal:  "1-ff00:0:110,127.0.0.2:0",
+		},
+		"discovered AS replaces default ISD-AS": {
+			args:       []string{"-gen", "testdata/gen", "-ia", "1-ff00:0:111"},
+			wantDaemon: "127.0.0.19:30255",
+			wantLocal:  "1-ff00:0:111,127.0.0.2:0",
+		},
+		"explicit daemon takes precedence": {
+			args: []string{"-gen", "testdata/gen", "-ia", "1-ff00:0:111",
+				"-daemon", "[::1]:1"},
+			wantDaemon: "[::1]:1",
+			wantLocal:  "1-ff00:0:111,127.0.0.2:0",
+		},
+		"ISD-AS of explicit local": {
+			args:       []string{"-gen", "testdata/gen", "-local", "1-ff00:0:111,127.0.0.5:7"},
+			wantDaemon: "127.0.0.19:30255",
+			wantLocal:  "1-ff00:0:111,127.0.0.5:7",
+		},
+		"explicit local in other ISD-AS": {
+			args: []string{"-gen", "testdata/gen", "-ia", "1-ff00:0:111",
+				"-local", "1-ff00:0:110,127.0.0.5:7"},
+		},
+		"unknown AS": {
+			args: []string{"-gen", "testdata/gen", "-ia", "1-ff00:0:112"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := parse(t, tc.args, true)
+			err := cfg.Resolve(defaults)
+			if tc.wantLocal == "" {
+				if err == nil {
+					t.Errorf("Resolve() succeeded with local %v, want error", &cfg.Local)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Daemon != tc.wantDaemon {
+				t.Errorf("daemon = %q, want %q", cfg.Daemon, tc.wantDaemon)
+			}
+			if got := cfg.Local.String(); got != tc.wantLocal {
+				t.Errorf("local = %q, want %q", got, tc.wantLocal)
+			}
+			if got := cfg.Remote.String(); got != defaults.Remote {
+				t.Errorf("remote = %q, want %q", got, defaults.Remote)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() config.Config {
+		return config.Config{
+			Daemon: "127.0.0.1:30255",
+			Local:  mustUDPAddr("1-ff00:0:110,127.0.0.2:0"),
+			Remote: mustUDPAddr("1-ff00:0:112,[::1]:8080"),
+		}
+	}
+	tests := map[string]struct {
+		modify func(c *config.Config)
+		valid  bool
+	}{
+		"valid": {
+			modify: func(c *config.Config) {},
+			valid:  true,
+		},
+		"without daemon": {
+			modify: func(c *config.Config) { c.Daemon = "" },
+			valid:  true,
+		},
+		"invalid daemon": {
+			modify: func(c *config.Config) { c.Daemon = "127.0.0.1" },
+		},
+		"missing local": {
+			modify: func(c *config.Config) { c.Local = snet.UDPAddr{} },
+		},
+		"unspecified local IPv4": {
+			modify: func(c *config.Config) { c.Local = mustUDPAddr("1-ff00:0:110,0.0.0.0:0") },
+		},
+		"unspecified local IPv6": {
+			modify: func(c *config.Config) { c.Local = mustUDPAddr("1-ff00:0:110,[::]:0") },
+		},
+		"listen in local ISD-AS": {
+			modify: func(c *config.Config) {
+				c.Listen = []snet.UDPAddr{mustUDPAddr("1-ff00:0:110,[::1]:0")}
+			},
+			valid: true,
+		},
+		"listen in other ISD-AS": {
+			modify: func(c *config.Config) {
+				c.Listen = []snet.UDPAddr{mustUDPAddr("1-ff00:0:111,[::1]:0")}
+			},
+		},
+		"unspecified listen IP": {
+			modify: func(c *config.Config) {
+				c.Listen = []snet.UDPAddr{mustUDPAddr("1-ff00:0:110,[::]:0")}
+			},
+		},
+		"missing remote": {
+			modify: func(c *config.Config) { c.Remote = snet.UDPAddr{} },
+		},
+		"unspecified remote IP": {
+			modify: func(c *config.Config) { c.Remote = mustUDPAddr("1-ff00:0:112,[::]:8080") },
+		},
+		"missing remote port": {
+			modify: func(c *config.Config) { c.Remote = mustUDPAddr("1-ff00:0:112,[::1]:0") },
+		},
+		"invalid metrics address": {
+			modify: func(c *config.Config) { c.MetricsAddr = "9100" },
+		},
+		"negative metrics path bound": {
+			modify: func(c *config.Config) { c.MetricsMaxPaths = -1 },
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := valid()
+			cfg.RegisterRemoteFlag(flag.NewFlagSet("cmd", flag.ContinueOnError))
+			tc.modify(&cfg)
+			err := cfg.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("Validate() = nil, want error")
+			}
+		})
+	}
+}
+
+func TestValidateOptionalRemote(t *testing.T) {
+	tests := map[string]struct {
+		remote string
+		valid  bool
+	}{
+		"without remote":      {valid: true},
+		"remote":              {remote: "1-ff00:0:112,[::1]:8080", valid: true},
+		"missing remote port": {remote: "1-ff00:0:112,[::1]:0"},
+		"unspecified remote":  {remote: "1-ff00:0:112,[::]:8080"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.Config{Local: mustUDPAddr("1-ff00:0:110,127.0.0.2:0")}
+			cfg.RegisterOptionalRemoteFlag(flag.NewFlagSet("cmd", flag.ContinueOnError))
+			if tc.remote != "" {
+				cfg.Remote = mustUDPAddr(tc.remote)
+			}
+			err := cfg.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("Validate() = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Package connect sets up the SCION daemon connection and the packet connections that are
+// shared by all scion-hello commands.
+package connect
+
+import (
+	"context"
+	"net"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+	"github.com/scionproto/scion/pkg/snet/metrics"
+)
+
+var (
+	// Metrics are the packet connection metrics of all connections opened by this package.
+	Metrics = metrics.NewSCIONPacketConnMetrics()
+
+	// Dial connects to the SCION daemon at address. Tests replace it to run against a fake
+	// daemon, e.g. a fakedaemon.Connector.
+	Dial = func(ctx context.Context, address string) (daemon.Connector, error) {
+		// TODO the following is deprecated
+		return daemon.NewService(address).Connect(ctx)
+	}
+)
+
+// Daemon connects to the SCION daemon at address.
+func Daemon(ctx context.Context, address string) (daemon.Connector, error) {
+	if address == "" {
+		return nil, serrors.New("missing daemon address")
+	}
+	conn, err := Dial(ctx, address)
+	if err != nil {
+		return nil, serrors.WrapStr("connecting to daemon", err, "daemon", address)
+	}
+	return conn, nil
+}
+
+// NewConnector returns a connector for dispatcher-less packet connections. Revocations found
+// in SCMP errors are reported to dc.
+func NewConnector(dc daemon.Connector) *snet.DefaultConnector {
+	return &snet.DefaultConnector{
+		SCMPHandler: snet.DefaultSCMPHandler{
+			RevocationHandler: daemon.RevHandler{Connector: dc},
+			SCMPErrors:        Metrics.SCMPErrors,
+		},
+		Metrics: Metrics,
+	}
+}
+
+// Host is a SCION end host with a daemon connection and a packet connection.
+type Host struct {
+	// IA is the ISD-AS of the host.
+	IA addr.IA
+	// Daemon is the connection to the SCION daemon.
+	Daemon daemon.Connector
+	// Conn is the packet connection bound to the local address.
+	Conn snet.PacketConn
+}
+
+// Open connects to the daemon at daemonAddr and opens a packet connection on local.
+func Open(ctx context.Context, daemonAddr string, local *snet.UDPAddr) (*Host, error) {
+	dc, err := Daemon(ctx, daemonAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := NewConnector(dc).OpenUDP(local.Host)
+	if err != nil {
+		dc.Close()
+		return nil, serrors.WrapStr("opening packet connection", err, "local", local)
+	}
+	return &Host{
+		IA:     local.IA,
+		Daemon: dc,
+		Conn:   conn,
+	}, nil
+}
+
+// LocalAddr returns the local UDP address of the packet connection.
+func (h *Host) LocalAddr() *net.UDPAddr {
+	return h.Conn.LocalAddr().(*net.UDPAddr)
+}
+
+// Close closes the packet connection and the daemon connection.
+func (h *Host) Close() error {
+	err := h.Conn.Close()
+	if derr := h.Daemon.Close(); err == nil {
+		err = derr
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+// Package discovery finds the SCION daemon and the border routers of an AS in the gen/
+// directory that is created by `./scion.sh topology`.
+//
+// For an AS 1-ff00:0:110 the relevant files are gen/ASff00_0_110/sd.toml, which contains the
+// daemon address in its [sd] section, and gen/ASff00_0_110/topology.json.
+package discovery
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/pelletier/go-toml"
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/private/topology"
+)
+
+const (
+	sdFile       = "sd.toml"
+	topologyFile = "topology.json"
+)
+
+// AS is the information about a local AS found in a gen/ directory.
+type AS struct {
+	// IA is the ISD-AS of the AS.
+	IA addr.IA
+	// Daemon is the address of the SCION daemon API.
+	Daemon string
+	// BorderRouters maps border router names to their internal underlay addresses.
+	BorderRouters map[string]*net.UDPAddr
+	// Interfaces maps interface IDs to the internal underlay address of the border router
+	// that owns the interface.
+	Interfaces map[common.IFIDType]*net.UDPAddr
+}
+
+// Dir returns the directory of AS ia in gen, e.g. gen/ASff00_0_110.
+func Dir(gen string, ia addr.IA) string {
+	return filepath.Join(gen, "AS"+addr.FormatAS(ia.AS(), addr.WithFileSeparator()))
+}
+
+// Load reads the daemon and topology configuration of AS ia from gen. If ia is the zero value,
+// gen is expected to be the directory of a single AS, e.g. gen/ASff00_0_110.
+func Load(gen string, ia addr.IA) (*AS, error) {
+	dir := gen
+	if !ia.IsZero() {
+		dir = Dir(gen, ia)
+	}
+	topo, err := topology.RWTopologyFromJSONFile(filepath.Join(dir, topologyFile))
+	if err != nil {
+		return nil, serrors.WrapStr("loading topology", err, "dir", dir)
+	}
+	if !ia.IsZero() && !topo.IA.Equal(ia) {
+		return nil, serrors.New("topology belongs to different ISD-AS",
+			"dir", dir, "expected", ia, "actual", topo.IA)
+	}
+	daemonAddr, err := loadDaemonAddr(filepath.Join(dir, sdFile))
+	if err != nil {
+		return nil, err
+	}
+	as := &AS{
+		IA:            topo.IA,
+		Daemon:        daemonAddr,
+		BorderRouters: make(map[string]*net.UDPAddr, len(topo.BR)),
+		Interfaces:    make(map[common.IFIDType]*net.UDPAddr, len(topo.IFInfoMap)),
+	}
+	for name, br := range topo.BR {
+		as.BorderRouters[name] = br.InternalAddr
+		for _, ifID := range br.IFIDs {
+			as.Interfaces[ifID] = br.InternalAddr
+		}
+	}
+	return as, nil
+}
+
+// loadDaemonAddr reads the API address from the [sd] section of a daemon configuration.
+func loadDaemonAddr(path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", serrors.WrapStr("reading daemon config", err)
+	}
+	var cfg struct {
+		SD struct {
+			Address string `toml:"address"`
+		} `toml:"sd"`
+	}
+	if err := toml.Unmarshal(raw, &cfg); err != nil {
+		return "", serrors.WrapStr("parsing daemon config", err, "file", path)
+	}
+	if cfg.SD.Address == "" {
+		return daemon.DefaultAPIAddress, nil
+	}
+	if _, _, err := net.SplitHostPort(cfg.SD.Address); err != nil {
+		return "", serrors.WrapStr("invalid daemon address", err,
+			"file", path, "address", cfg.SD.Address)
+	}
+	return cfg.SD.Address, nil
+}
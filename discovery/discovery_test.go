@@ -0,0 +1,124 @@
+package discovery_test
+
+import (
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/private/common"
+
+	"github.com/tzaeschke/scion-hello/discovery"
+)
+
+var (
+	ia110 = mustParseIA("1-ff00:0:110")
+	ia111 = mustParseIA("1-ff00:0:111")
+)
+
+func mustParseIA(s string) addr.IA {
+	ia, err := addr.ParseIA(s)
+	if err != nil {
+		panic(err)
+	}
+	return ia
+}
+
+func TestLoad(t *testing.T) {
+	br1 := &net.UDPAddr{IP: net.ParseIP("127.0.0.9"), Port: 31002}
+	br2 := &net.UDPAddr{IP: net.ParseIP("fd00:f00d:cafe::7f00:a"), Port: 31004}
+	want110 := &discovery.AS{
+		IA:     ia110,
+		Daemon: "[127.0.0.12]:30255",
+		BorderRouters: map[string]*net.UDPAddr{
+			"br1-ff00_0_110-1": br1,
+			"br1-ff00_0_110-2": br2,
+		},
+		Interfaces: map[common.IFIDType]*net.UDPAddr{1: br1, 2: br2, 3: br1},
+	}
+	br111 := &net.UDPAddr{IP: net.ParseIP("127.0.0.17"), Port: 31010}
+	want111 := &discovery.AS{
+		IA:            ia111,
+		Daemon:        daemon.DefaultAPIAddress,
+		BorderRouters: map[string]*net.UDPAddr{"br1-ff00_0_111-1": br111},
+		Interfaces:    map[common.IFIDType]*net.UDPAddr{41: br111},
+	}
+
+	tests := map[string]struct {
+		gen  string
+		ia   addr.IA
+		want *discovery.AS
+	}{
+		"AS in gen": {
+			gen:  "testdata/gen",
+			ia:   ia110,
+			want: want110,
+		},
+		"AS directory": {
+			gen:  discovery.Dir("testdata/gen", ia110),
+			want: want110,
+		},
+		"default daemon address": {
+			gen:  "testdata/gen",
+			ia:   ia111,
+			want: want111,
+		},
+		"missing AS": {
+			gen: "testdata/gen",
+			ia:  mustParseIA("1-ff00:0:112"),
+		},
+		"topology of other AS": {
+			gen: discovery.Dir("testdata/gen", ia110),
+			ia:  ia111,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			as, err := discovery.Load(tc.gen, tc.ia)
+			if tc.want == nil {
+				if err == nil {
+					t.Errorf("Load() = %+v, want error", as)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(normalize(as), normalize(tc.want)) {
+				t.Errorf("Load() = %+v, want %+v", as, tc.want)
+			}
+		})
+	}
+}
+
+func TestDir(t *testing.T) {
+	want := filepath.Join("gen", "ASff00_0_110")
+	if got := discovery.Dir("gen", ia110); got != want {
+		t.Errorf("Dir() = %q, want %q", got, want)
+	}
+}
+
+// normalize returns a copy of as with the IPv4 addresses in their 4 byte form, so that
+// addresses compare equal regardless of how they were parsed.
+func normalize(as *discovery.AS) *discovery.AS {
+	cp := *as
+	cp.BorderRouters = make(map[string]*net.UDPAddr, len(as.BorderRouters))
+	for name, a := range as.BorderRouters {
+		cp.BorderRouters[name] = normalizeAddr(a)
+	}
+	cp.Interfaces = make(map[common.IFIDType]*net.UDPAddr, len(as.Interfaces))
+	for id, a := range as.Interfaces {
+		cp.Interfaces[id] = normalizeAddr(a)
+	}
+	return &cp
+}
+
+func normalizeAddr(a *net.UDPAddr) *net.UDPAddr {
+	cp := *a
+	if ip4 := a.IP.To4(); ip4 != nil {
+		cp.IP = ip4
+	}
+	return &cp
+}
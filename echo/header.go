@@ -0,0 +1,238 @@
+package echo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/scrypto"
+)
+
+const (
+	// Version is the version of the message header written by this package.
+	Version = 1
+	// HeaderLen is the length of a version 1 message header.
+	HeaderLen = 32
+)
+
+// headerMagic starts every message header.
+var headerMagic = []byte("SCHL")
+
+var (
+	// ErrNoHeader is returned by ParseHeader if the payload has no message header.
+	ErrNoHeader = serrors.New("no message header")
+	// ErrVersion is returned by ParseHeader for headers of an unknown version.
+	ErrVersion = serrors.New("unsupported message header version")
+)
+
+// Header is the message header of the hello protocol. Clients put it in front of the message
+// to match replies to requests; the server answers with the same header in front of the reply.
+//
+// The header is encoded as follows, all integers in network byte order:
+//
+//	 0: magic "SCHL"
+//	 4: version (1 byte), 3 reserved bytes
+//	 8: sequence number (8 bytes)
+//	16: send time in nanoseconds since the Unix epoch (8 bytes)
+//	24: client nonce (8 bytes)
+type Header struct {
+	Version uint8
+	// Seq is the sequence number of the request.
+	Seq uint64
+	// Sent is the time the client sent the request.
+	Sent time.Time
+	// Nonce identifies the client, so that replies to other clients sharing the address can
+	// be told apart.
+	Nonce uint64
+}
+
+// AppendTo appends the encoded header to b.
+func (h Header) AppendTo(b []byte) []byte {
+	b = append(b, headerMagic...)
+	b = append(b, h.Version, 0, 0, 0)
+	b = binary.BigEndian.AppendUint64(b, h.Seq)
+	b = binary.BigEndian.AppendUint64(b, uint64(h.Sent.UnixNano()))
+	return binary.BigEndian.AppendUint64(b, h.Nonce)
+}
+
+// ParseHeader parses the message header at the start of payload and returns it together with
+// the message following it.
+func ParseHeader(payload []byte) (Header, []byte, error) {
+	if !bytes.HasPrefix(payload, headerMagic) || len(payload) < len(headerMagic)+1 {
+		return Header{}, payload, ErrNoHeader
+	}
+	version := payload[len(headerMagic)]
+	if version != Version {
+		return Header{}, payload, serrors.WithCtx(ErrVersion, "version", version)
+	}
+	if len(payload) < HeaderLen {
+		return Header{}, payload, serrors.New("truncated message header", "len", len(payload))
+	}
+	return Header{
+		Version: version,
+		Seq:     binary.BigEndian.Uint64(payload[8:]),
+		Sent:    time.Unix(0, int64(binary.BigEndian.Uint64(payload[16:]))),
+		Nonce:   binary.BigEndian.Uint64(payload[24:]),
+	}, payload[HeaderLen:], nil
+}
+
+// ReplyKind classifies a reply received by a Tracker.
+type ReplyKind int
+
+const (
+	// InOrder is the first reply to the newest request answered so far.
+	InOrder ReplyKind = iota
+	// Reordered is the first reply to a request that was sent before the newest request
+	// answered so far.
+	Reordered
+	// Duplicate is a further reply to an already answered request.
+	Duplicate
+	// Stray is a reply to a request that was not sent by the tracker, e.g. because the
+	// nonce is wrong.
+	Stray
+)
+
+func (k ReplyKind) String() string {
+	switch k {
+	case InOrder:
+		return "in order"
+	case Reordered:
+		return "reordered"
+	case Duplicate:
+		return "duplicate"
+	case Stray:
+		return "stray"
+	default:
+		return "unknown"
+	}
+}
+
+// Reply is a reply matched by a Tracker.
+type Reply struct {
+	Kind ReplyKind
+	Seq  uint64
+	// RTT is the round trip time of the request. It is only set for InOrder and Reordered
+	// replies.
+	RTT time.Duration
+}
+
+// TrackerStats are the counters of a Tracker.
+type TrackerStats struct {
+	// Sent is the number of requests sent.
+	Sent uint64
+	// Answered is the number of requests that got a reply.
+	Answered uint64
+	// Reordered, Duplicates and Stray count the replies of the respective kind.
+	Reordered, Duplicates, Stray uint64
+}
+
+// maxAnswered is the number of answered sequence numbers a Tracker remembers to detect
+// duplicates.
+const maxAnswered = 4096
+
+// Tracker creates the headers of the requests of a client and matches the replies to them.
+// It is not safe for concurrent use.
+type Tracker struct {
+	nonce   uint64
+	next    uint64
+	newest  uint64
+	pending map[uint64]time.Time
+	// answered are the recently answered sequence numbers.
+	answered map[uint64]struct{}
+	stats    TrackerStats
+}
+
+// NewTracker returns a tracker with a random nonce.
+func NewTracker() *Tracker {
+	return &Tracker{
+		nonce:    scrypto.RandUint64(),
+		next:     1,
+		pending:  make(map[uint64]time.Time),
+		answered: make(map[uint64]struct{}),
+	}
+}
+
+// Next returns the header of the next request, sent at now.
+func (t *Tracker) Next(now time.Time) Header {
+	h := Header{Version: Version, Seq: t.next, Sent: now, Nonce: t.nonce}
+	t.pending[h.Seq] = now
+	t.next++
+	t.stats.Sent++
+	return h
+}
+
+// Match matches the header of a reply received at now.
+func (t *Tracker) Match(h Header, now time.Time) Reply {
+	r := Reply{Seq: h.Seq}
+	if h.Nonce != t.nonce || h.Seq == 0 || h.Seq >= t.next {
+		r.Kind = Stray
+		t.stats.Stray++
+		return r
+	}
+	sent, ok := t.pending[h.Seq]
+	if !ok {
+		if _, ok := t.answered[h.Seq]; ok || h.Seq+maxAnswered <= t.newest {
+			r.Kind = Duplicate
+			t.stats.Duplicates++
+		} else {
+			// Forgotten by Forget, e.g. after a timeout.
+			r.Kind = Stray
+			t.stats.Stray++
+		}
+		return r
+	}
+	delete(t.pending, h.Seq)
+	t.answer(h.Seq)
+	t.stats.Answered++
+	r.RTT = now.Sub(sent)
+	if h.Seq < t.newest {
+		r.Kind = Reordered
+		t.stats.Reordered++
+		return r
+	}
+	t.newest = h.Seq
+	return r
+}
+
+// Forget stops waiting for request seq, e.g. because it timed out. Later replies to it are
+// counted as stray.
+func (t *Tracker) Forget(seq uint64) {
+	delete(t.pending, seq)
+}
+
+// Expire stops waiting for the requests sent before deadline, e.g. because they timed out,
+// and returns their number. Later replies to them are counted as stray.
+func (t *Tracker) Expire(deadline time.Time) int {
+	n := 0
+	for seq, sent := range t.pending {
+		if sent.Before(deadline) {
+			delete(t.pending, seq)
+			n++
+		}
+	}
+	return n
+}
+
+// Pending returns the number of requests waiting for a reply.
+func (t *Tracker) Pending() int {
+	return len(t.pending)
+}
+
+// Stats returns the counters of the tracker.
+func (t *Tracker) Stats() TrackerStats {
+	return t.stats
+}
+
+// answer remembers that seq was answered.
+func (t *Tracker) answer(seq uint64) {
+	t.answered[seq] = struct{}{}
+	if len(t.answered) <= maxAnswered {
+		return
+	}
+	for s := range t.answered {
+		if s+maxAnswered <= seq {
+			delete(t.answered, s)
+		}
+	}
+}
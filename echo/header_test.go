@@ -0,0 +1,220 @@
+package echo_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tzaeschke/scion-hello/echo"
+)
+
+func TestParseHeader(t *testing.T) {
+	h := echo.Header{Version: echo.Version, Seq: 7, Sent: time.Unix(0, 42), Nonce: 99}
+	valid := append(h.AppendTo(nil), "hello"...)
+	wrongVersion := append([]byte(nil), valid...)
+	wrongVersion[4] = echo.Version + 1
+
+	tests := map[string]struct {
+		payload []byte
+		// err is the expected error, nil for a valid header.
+		err error
+		// truncated is set if parsing fails for a header that is too short.
+		truncated bool
+	}{
+		"valid":             {payload: valid},
+		"empty":             {payload: nil, err: echo.ErrNoHeader},
+		"no magic":          {payload: []byte("hello"), err: echo.ErrNoHeader},
+		"magic only":        {payload: []byte("SCHL"), err: echo.ErrNoHeader},
+		"unknown version":   {payload: wrongVersion, err: echo.ErrVersion},
+		"truncated":         {payload: valid[:echo.HeaderLen-1], truncated: true},
+		"magic and version": {payload: valid[:5], truncated: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, msg, err := echo.ParseHeader(tc.payload)
+			switch {
+			case tc.truncated:
+				if err == nil || errors.Is(err, echo.ErrNoHeader) ||
+					errors.Is(err, echo.ErrVersion) {
+
+					t.Errorf("ParseHeader() = %v, want a truncated header error", err)
+				}
+			case tc.err != nil:
+				if !errors.Is(err, tc.err) {
+					t.Errorf("ParseHeader() = %v, want %v", err, tc.err)
+				}
+				if string(msg) != string(tc.payload) {
+					t.Errorf("message = %q, want the whole payload", msg)
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Seq != h.Seq || !got.Sent.Equal(h.Sent) || got.Nonce != h.Nonce ||
+					got.Version != h.Version {
+
+					t.Errorf("header = %+v, want %+v", got, h)
+				}
+				if string(msg) != "hello" {
+					t.Errorf("message = %q, want %q", msg, "hello")
+				}
+			}
+		})
+	}
+}
+
+// trackerStep is a step of a Tracker test. It matches a reply to seq, unless forget or
+// expire are set.
+type trackerStep struct {
+	seq uint64
+	// otherNonce replies with the nonce of another client.
+	otherNonce bool
+	// forget forgets request seq instead.
+	forget bool
+	// expire expires all requests instead.
+	expire bool
+	want   echo.ReplyKind
+}
+
+func TestTrackerMatch(t *testing.T) {
+	match := func(seq uint64, want echo.ReplyKind) trackerStep {
+		return trackerStep{seq: seq, want: want}
+	}
+	tests := map[string]struct {
+		steps       []trackerStep
+		wantStats   echo.TrackerStats
+		wantPending int
+	}{
+		"in order": {
+			steps: []trackerStep{
+				match(1, echo.InOrder), match(2, echo.InOrder), match(3, echo.InOrder),
+			},
+			wantStats: echo.TrackerStats{Sent: 3, Answered: 3},
+		},
+		"newest first": {
+			steps: []trackerStep{
+				match(3, echo.InOrder), match(1, echo.Reordered), match(2, echo.Reordered),
+			},
+			wantStats: echo.TrackerStats{Sent: 3, Answered: 3, Reordered: 2},
+		},
+		"one late": {
+			steps: []trackerStep{
+				match(2, echo.InOrder), match(3, echo.InOrder), match(1, echo.Reordered),
+			},
+			wantStats: echo.TrackerStats{Sent: 3, Answered: 3, Reordered: 1},
+		},
+		"duplicate after match": {
+			steps: []trackerStep{
+				match(1, echo.InOrder), match(1, echo.Duplicate), match(2, echo.InOrder),
+			},
+			wantStats:   echo.TrackerStats{Sent: 3, Answered: 2, Duplicates: 1},
+			wantPending: 1,
+		},
+		"duplicate of reordered reply": {
+			steps: []trackerStep{
+				match(2, echo.InOrder), match(1, echo.Reordered), match(1, echo.Duplicate),
+				match(2, echo.Duplicate),
+			},
+			wantStats:   echo.TrackerStats{Sent: 3, Answered: 2, Reordered: 1, Duplicates: 2},
+			wantPending: 1,
+		},
+		"stray": {
+			steps: []trackerStep{
+				{seq: 1, otherNonce: true, want: echo.Stray},
+				match(0, echo.Stray),
+				match(4, echo.Stray),
+				match(1, echo.InOrder),
+			},
+			wantStats:   echo.TrackerStats{Sent: 3, Answered: 1, Stray: 3},
+			wantPending: 2,
+		},
+		"after Forget": {
+			steps: []trackerStep{
+				{seq: 2, forget: true},
+				match(2, echo.Stray),
+				match(1, echo.InOrder),
+				match(3, echo.InOrder),
+			},
+			wantStats: echo.TrackerStats{Sent: 3, Answered: 2, Stray: 1},
+		},
+		"after Expire": {
+			steps: []trackerStep{
+				match(1, echo.InOrder),
+				{expire: true},
+				match(2, echo.Stray),
+				match(1, echo.Duplicate),
+			},
+			wantStats: echo.TrackerStats{Sent: 3, Answered: 1, Duplicates: 1, Stray: 1},
+		},
+	}
+	start := time.Unix(1000, 0)
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tr := echo.NewTracker()
+			var headers []echo.Header
+			for i := 0; i < 3; i++ {
+				sent := start.Add(time.Duration(i) * time.Millisecond)
+				headers = append(headers, tr.Next(sent))
+			}
+			if headers[0].Seq != 1 || headers[2].Seq != 3 ||
+				headers[0].Nonce != headers[2].Nonce {
+
+				t.Fatalf("headers = %+v, want sequence numbers 1 to 3 with one nonce", headers)
+			}
+			now := start.Add(10 * time.Millisecond)
+			for i, s := range tc.steps {
+				switch {
+				case s.forget:
+					tr.Forget(s.seq)
+					continue
+				case s.expire:
+					pending := tr.Pending()
+					if n := tr.Expire(now); n != pending {
+						t.Errorf("step %d: Expire() = %d, want %d", i, n, pending)
+					}
+					continue
+				}
+				h := echo.Header{Version: echo.Version, Seq: s.seq, Nonce: headers[0].Nonce}
+				if s.otherNonce {
+					h.Nonce++
+				}
+				r := tr.Match(h, now)
+				if r.Kind != s.want || r.Seq != s.seq {
+					t.Errorf("step %d: Match(%d) = %v %d, want %v", i, s.seq, r.Kind, r.Seq,
+						s.want)
+				}
+				wantRTT := time.Duration(0)
+				if s.want == echo.InOrder || s.want == echo.Reordered {
+					wantRTT = time.Duration(11-s.seq) * time.Millisecond
+				}
+				if r.RTT != wantRTT {
+					t.Errorf("step %d: RTT = %v, want %v", i, r.RTT, wantRTT)
+				}
+			}
+			if got := tr.Stats(); got != tc.wantStats {
+				t.Errorf("Stats() = %+v, want %+v", got, tc.wantStats)
+			}
+			if got := tr.Pending(); got != tc.wantPending {
+				t.Errorf("Pending() = %d, want %d", got, tc.wantPending)
+			}
+		})
+	}
+}
+
+func TestTrackerExpire(t *testing.T) {
+	tr := echo.NewTracker()
+	start := time.Unix(1000, 0)
+	for i := 0; i < 4; i++ {
+		tr.Next(start.Add(time.Duration(i) * time.Second))
+	}
+	// Requests 1 and 2 were sent before the deadline, request 3 at the deadline.
+	if n := tr.Expire(start.Add(2 * time.Second)); n != 2 {
+		t.Errorf("Expire() = %d, want 2", n)
+	}
+	if n := tr.Pending(); n != 2 {
+		t.Errorf("Pending() = %d, want 2", n)
+	}
+	if n := tr.Expire(start.Add(2 * time.Second)); n != 0 {
+		t.Errorf("second Expire() = %d, want 0", n)
+	}
+}
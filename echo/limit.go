@@ -0,0 +1,206 @@
+package echo
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/private/serrors"
+)
+
+// maxBuckets is the maximum number of token buckets a limiter keeps per limit.
+const maxBuckets = 1 << 16
+
+// Rate is a token bucket rate limit. The zero value does not limit.
+type Rate struct {
+	// PerSecond is the number of packets per second that is refilled.
+	PerSecond float64
+	// Burst is the size of the bucket. If 0, it is max(1, PerSecond).
+	Burst int
+}
+
+func (r Rate) burst() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	if r.PerSecond < 1 {
+		return 1
+	}
+	return r.PerSecond
+}
+
+// Limits restrict which sources the server answers and how often.
+type Limits struct {
+	// PerIA limits the packets answered per source ISD-AS.
+	PerIA Rate
+	// PerHost limits the packets answered per source host, i.e. ISD-AS and IP.
+	PerHost Rate
+	// Allow are the source ISD-ASes to answer. If empty, all are allowed.
+	Allow []IARange
+	// Deny are the source ISD-ASes to drop. Deny takes precedence over Allow.
+	Deny []IARange
+}
+
+// IARange is a range of ISD-ASes: either a single ISD-AS, all ASes of an ISD, or an
+// interval of ASes in an ISD. A zero ISD matches all ISDs.
+type IARange struct {
+	ISD         addr.ISD
+	First, Last addr.AS
+}
+
+// ParseIARange parses an ISD-AS range: "1-ff00:0:110" for a single AS, "1-0" for all ASes in
+// ISD 1, "0-0" for all ASes, and "1-ff00:0:110-ff00:0:1ff" for an interval of ASes.
+func ParseIARange(s string) (IARange, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 && len(parts) != 3 {
+		return IARange{}, serrors.New("invalid ISD-AS range", "range", s)
+	}
+	isd, err := addr.ParseISD(parts[0])
+	if err != nil {
+		return IARange{}, serrors.WrapStr("parsing ISD", err, "range", s)
+	}
+	first, err := addr.ParseAS(parts[1])
+	if err != nil {
+		return IARange{}, serrors.WrapStr("parsing AS", err, "range", s)
+	}
+	r := IARange{ISD: isd, First: first, Last: first}
+	if len(parts) == 3 {
+		if r.Last, err = addr.ParseAS(parts[2]); err != nil {
+			return IARange{}, serrors.WrapStr("parsing AS", err, "range", s)
+		}
+		if r.Last < r.First {
+			return IARange{}, serrors.New("empty ISD-AS range", "range", s)
+		}
+	} else if first == 0 {
+		r.Last = addr.MaxAS
+	}
+	return r, nil
+}
+
+// Contains returns whether ia is in the range.
+func (r IARange) Contains(ia addr.IA) bool {
+	if r.ISD != 0 && r.ISD != ia.ISD() {
+		return false
+	}
+	return ia.AS() >= r.First && ia.AS() <= r.Last
+}
+
+func (r IARange) String() string {
+	if r.First == r.Last {
+		return addr.MustIAFrom(r.ISD, r.First).String()
+	}
+	if r.First == 0 && r.Last == addr.MaxAS {
+		return addr.MustIAFrom(r.ISD, 0).String()
+	}
+	return addr.MustIAFrom(r.ISD, r.First).String() + "-" + r.Last.String()
+}
+
+// allowed returns whether packets from ia may be answered.
+func (l *Limits) allowed(ia addr.IA) bool {
+	for _, r := range l.Deny {
+		if r.Contains(ia) {
+			return false
+		}
+	}
+	if len(l.Allow) == 0 {
+		return true
+	}
+	for _, r := range l.Allow {
+		if r.Contains(ia) {
+			return true
+		}
+	}
+	return false
+}
+
+// limiter keeps the token buckets of the source ISD-ASes and hosts.
+type limiter struct {
+	mu    sync.Mutex
+	ias   buckets
+	hosts buckets
+}
+
+// allow takes a token from the bucket of ISD-AS ia with rate l.PerIA and one from the bucket
+// of host with rate l.PerHost. Tokens are only taken if both buckets have one, so that packets
+// dropped by one limit do not use up the other.
+func (lim *limiter) allow(l Limits, ia, host string, now time.Time) bool {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	iaBucket := lim.ias.refill(l.PerIA, ia, now)
+	hostBucket := lim.hosts.refill(l.PerHost, host, now)
+	if iaBucket.empty() || hostBucket.empty() {
+		return false
+	}
+	iaBucket.take()
+	hostBucket.take()
+	return true
+}
+
+// buckets are token buckets by key.
+type buckets map[string]*bucket
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill returns the bucket of key with rate r, refilled up to now. It returns nil if r does
+// not limit.
+func (bs *buckets) refill(r Rate, key string, now time.Time) *bucket {
+	if r.PerSecond <= 0 {
+		return nil
+	}
+	burst := r.burst()
+	if *bs == nil {
+		*bs = make(buckets)
+	}
+	b, ok := (*bs)[key]
+	if !ok {
+		if len(*bs) >= maxBuckets {
+			bs.evict(r, now)
+		}
+		b = &bucket{tokens: burst, last: now}
+		(*bs)[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * r.PerSecond
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	return b
+}
+
+// evict makes room for new buckets. It removes the buckets that are full again, as they
+// behave like new ones. If that is not enough, it removes the least recently used buckets
+// down to 7/8 of maxBuckets, so that the buckets are not sorted again for every new key.
+func (bs buckets) evict(r Rate, now time.Time) {
+	for key, b := range bs {
+		if b.tokens+now.Sub(b.last).Seconds()*r.PerSecond >= r.burst() {
+			delete(bs, key)
+		}
+	}
+	if len(bs) < maxBuckets {
+		return
+	}
+	keys := make([]string, 0, len(bs))
+	for key := range bs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return bs[keys[i]].last.Before(bs[keys[j]].last) })
+	for _, key := range keys[:len(keys)-maxBuckets*7/8] {
+		delete(bs, key)
+	}
+}
+
+// empty returns whether a limiting bucket has no token left. A nil bucket does not limit.
+func (b *bucket) empty() bool {
+	return b != nil && b.tokens < 1
+}
+
+func (b *bucket) take() {
+	if b != nil {
+		b.tokens--
+	}
+}
@@ -0,0 +1,55 @@
+package echo
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLimiterTakesTokensOnlyIfBothAllow(t *testing.T) {
+	l := Limits{
+		PerIA:   Rate{PerSecond: 0.001, Burst: 2},
+		PerHost: Rate{PerSecond: 0.001, Burst: 1},
+	}
+	var lim limiter
+	now := time.Now()
+	steps := []struct {
+		host string
+		want bool
+	}{
+		{"a", true},
+		// Dropped by the host limit, the token of the ISD-AS is kept for b.
+		{"a", false},
+		{"b", true},
+		// Dropped by the ISD-AS limit, the token of c is kept.
+		{"c", false},
+	}
+	for i, s := range steps {
+		if got := lim.allow(l, "1-ff00:0:110", s.host, now); got != s.want {
+			t.Errorf("step %d: allow(%s) = %v, want %v", i, s.host, got, s.want)
+		}
+	}
+	if tokens := lim.hosts["c"].tokens; tokens != 1 {
+		t.Errorf("host c has %v tokens, want 1", tokens)
+	}
+}
+
+func TestLimiterCapsBuckets(t *testing.T) {
+	r := Rate{PerSecond: 0.001, Burst: 2}
+	var bs buckets
+	start := time.Now()
+	// None of the buckets is full again, so the least recently used ones are evicted.
+	n := maxBuckets + 100
+	for i := 0; i < n; i++ {
+		bs.refill(r, strconv.Itoa(i), start.Add(time.Duration(i)*time.Millisecond)).take()
+	}
+	if len(bs) > maxBuckets {
+		t.Fatalf("%d buckets, want at most %d", len(bs), maxBuckets)
+	}
+	if _, ok := bs["0"]; ok {
+		t.Error("least recently used bucket was kept")
+	}
+	if _, ok := bs[strconv.Itoa(n-1)]; !ok {
+		t.Error("most recently used bucket was evicted")
+	}
+}
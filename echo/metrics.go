@@ -0,0 +1,98 @@
+package echo
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/metrics"
+	"github.com/scionproto/scion/pkg/slayers"
+	"github.com/scionproto/scion/pkg/slayers/path"
+	"github.com/scionproto/scion/pkg/slayers/path/empty"
+	"github.com/scionproto/scion/pkg/slayers/path/epic"
+	"github.com/scionproto/scion/pkg/slayers/path/scion"
+	"github.com/scionproto/scion/pkg/snet"
+)
+
+// Names of the metric labels set by the server.
+const (
+	LabelRemoteIA = "remote_isd_as"
+	LabelPath     = "path"
+)
+
+// Metrics are the metrics of a Server. Nil metrics are not recorded.
+type Metrics struct {
+	// Handled counts the admitted requests, labeled with LabelRemoteIA and LabelPath.
+	Handled metrics.Counter
+	// Replies counts the replies sent, labeled with LabelRemoteIA and LabelPath.
+	Replies metrics.Counter
+	// DecodeFailures counts the requests with an unexpected payload or message header.
+	DecodeFailures metrics.Counter
+	// Oversized counts the requests dropped because the reply payload would have been larger
+	// than the request payload.
+	Oversized metrics.Counter
+	// ReversalFailures counts the requests whose path could not be reversed, labeled with
+	// LabelRemoteIA.
+	ReversalFailures metrics.Counter
+	// Labels returns the values of LabelRemoteIA and LabelPath for the remote ISD-AS and the
+	// PathFingerprint of a request, e.g. to bound their cardinality. If nil, ia.String() and
+	// fingerprint are used.
+	Labels func(ia addr.IA, fingerprint string) (string, string)
+}
+
+// labels returns the label name and value pairs of a request.
+func (m *Metrics) labels(ia addr.IA, fingerprint string) []string {
+	remote, fp := ia.String(), fingerprint
+	if m.Labels != nil {
+		remote, fp = m.Labels(ia, fingerprint)
+	}
+	return []string{LabelRemoteIA, remote, LabelPath, fp}
+}
+
+// DataplaneFingerprint returns the PathFingerprint of dataplane path dp.
+func DataplaneFingerprint(dp snet.DataplanePath) string {
+	var s slayers.SCION
+	if err := dp.SetPath(&s); err != nil {
+		return "unknown"
+	}
+	raw := make([]byte, s.Path.Len())
+	if err := s.Path.SerializeTo(raw); err != nil {
+		return "unknown"
+	}
+	return PathFingerprint(s.PathType, raw)
+}
+
+// PathFingerprint returns a short identifier of a raw dataplane path, e.g. for metric labels.
+// It only depends on the interfaces of the hop fields, so it is the same for the path sent by
+// the client and received by the server, and it survives path refreshes. Empty paths are
+// "empty", undecodable ones "unknown".
+func PathFingerprint(pathType path.Type, raw []byte) string {
+	switch pathType {
+	case empty.PathType:
+		return "empty"
+	case epic.PathType:
+		if len(raw) < epic.MetadataLen {
+			return "unknown"
+		}
+		raw = raw[epic.MetadataLen:]
+	case scion.PathType:
+	default:
+		return "unknown"
+	}
+	var p scion.Decoded
+	if err := p.DecodeFromBytes(raw); err != nil {
+		return "unknown"
+	}
+	h := sha256.New()
+	for _, segLen := range p.PathMeta.SegLen {
+		h.Write([]byte{segLen})
+	}
+	var b [4]byte
+	for _, hf := range p.HopFields {
+		binary.BigEndian.PutUint16(b[:2], hf.ConsIngress)
+		binary.BigEndian.PutUint16(b[2:], hf.ConsEgress)
+		h.Write(b[:])
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -0,0 +1,41 @@
+package echo
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/scionproto/scion/pkg/private/serrors"
+)
+
+// padMagic starts the payloads created by WithPadding.
+var padMagic = []byte("SCPD")
+
+// WithPadding returns msg prefixed with n bytes of padding. The server strips the padding
+// before it passes msg to the Responder, so the padding makes room for replies that are
+// longer than the message, e.g. of Prefix, Timestamp or JSON: a reply is never larger than
+// its request. Requests with a span context put the padding after it:
+//
+//	payload, err := echo.WithTrace(trace, echo.WithPadding(n, msg))
+func WithPadding(n int, msg []byte) ([]byte, error) {
+	if n < 0 || n > 0xffff {
+		return nil, serrors.New("invalid padding", "len", n)
+	}
+	payload := make([]byte, len(padMagic)+2+n, len(padMagic)+2+n+len(msg))
+	copy(payload, padMagic)
+	binary.BigEndian.PutUint16(payload[len(padMagic):], uint16(n))
+	return append(payload, msg...), nil
+}
+
+// SplitPadding removes the padding of a payload created by WithPadding and returns the
+// message. ok is false if the payload has no padding.
+func SplitPadding(payload []byte) (msg []byte, ok bool) {
+	if !bytes.HasPrefix(payload, padMagic) || len(payload) < len(padMagic)+2 {
+		return payload, false
+	}
+	rest := payload[len(padMagic):]
+	n := int(binary.BigEndian.Uint16(rest))
+	if len(rest) < 2+n {
+		return payload, false
+	}
+	return rest[2+n:], true
+}
@@ -0,0 +1,175 @@
+package echo
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+)
+
+// Request is a received message.
+type Request struct {
+	// Source and Destination are the SCION addresses of the packet, including the ports.
+	Source, Destination snet.UDPAddr
+	// Header is the message header of the request, nil if it has none. The server puts it
+	// in front of the reply.
+	Header *Header
+	// Payload is the UDP payload without message header and span context. It is only valid
+	// until Respond returns.
+	Payload []byte
+	// Underlay is the address of the border router the packet was received from.
+	Underlay *net.UDPAddr
+	// Received is the time the packet was received.
+	Received time.Time
+}
+
+// Responder computes the reply payload for a request. The server drops replies that are
+// larger than the request, including its padding, see WithPadding.
+type Responder interface {
+	Respond(req *Request) ([]byte, error)
+}
+
+// ResponderFunc is a function implementing Responder.
+type ResponderFunc func(req *Request) ([]byte, error)
+
+// Respond calls f(req).
+func (f ResponderFunc) Respond(req *Request) ([]byte, error) {
+	return f(req)
+}
+
+// NewResponderFunc creates a Responder from the argument of a responder specification,
+// e.g. "Re: " for "prefix:Re: ". The argument is empty if the specification has none.
+type NewResponderFunc func(arg string) (Responder, error)
+
+var (
+	respondersMu sync.RWMutex
+	responders   = map[string]NewResponderFunc{}
+)
+
+// Register makes a responder available under name to NewResponder. It panics if name is
+// already registered.
+func Register(name string, f NewResponderFunc) {
+	respondersMu.Lock()
+	defer respondersMu.Unlock()
+	if _, ok := responders[name]; ok {
+		panic("echo: responder registered twice: " + name)
+	}
+	responders[name] = f
+}
+
+// Responders returns the names of the registered responders, sorted.
+func Responders() []string {
+	respondersMu.RLock()
+	defer respondersMu.RUnlock()
+	names := make([]string, 0, len(responders))
+	for name := range responders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewResponder creates a responder from a specification "name" or "name:arg", e.g. "echo"
+// or "static:!DLROW ,OLLEh".
+func NewResponder(spec string) (Responder, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	respondersMu.RLock()
+	f, ok := responders[name]
+	respondersMu.RUnlock()
+	if !ok {
+		return nil, serrors.New("unknown responder", "name", name,
+			"known", strings.Join(Responders(), ","))
+	}
+	r, err := f(arg)
+	if err != nil {
+		return nil, serrors.WrapStr("creating responder", err, "spec", spec)
+	}
+	return r, nil
+}
+
+// Echo answers with the request payload.
+var Echo Responder = ResponderFunc(func(req *Request) ([]byte, error) {
+	return req.Payload, nil
+})
+
+// Reverse answers with the request payload in reverse byte order.
+var Reverse Responder = ResponderFunc(func(req *Request) ([]byte, error) {
+	reply := make([]byte, len(req.Payload))
+	for i, b := range req.Payload {
+		reply[len(reply)-1-i] = b
+	}
+	return reply, nil
+})
+
+// Timestamp answers with the receive time in RFC 3339 format.
+var Timestamp Responder = ResponderFunc(func(req *Request) ([]byte, error) {
+	return []byte(req.Received.UTC().Format(time.RFC3339Nano)), nil
+})
+
+// Prefix answers with the request payload, prefixed with p.
+func Prefix(p string) Responder {
+	return ResponderFunc(func(req *Request) ([]byte, error) {
+		return append([]byte(p), req.Payload...), nil
+	})
+}
+
+// Static answers every request with msg.
+func Static(msg string) Responder {
+	return ResponderFunc(func(req *Request) ([]byte, error) {
+		return []byte(msg), nil
+	})
+}
+
+// Diagnostics is the reply of the JSON responder.
+type Diagnostics struct {
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Underlay    string    `json:"underlay"`
+	Length      int       `json:"length"`
+	Received    time.Time `json:"received"`
+}
+
+// JSON answers with the Diagnostics of the request.
+var JSON Responder = ResponderFunc(func(req *Request) ([]byte, error) {
+	d := Diagnostics{
+		Source:      req.Source.String(),
+		Destination: req.Destination.String(),
+		Length:      len(req.Payload),
+		Received:    req.Received.UTC(),
+	}
+	if req.Underlay != nil {
+		d.Underlay = req.Underlay.String()
+	}
+	return json.Marshal(d)
+})
+
+func init() {
+	Register("echo", withoutArg(Echo))
+	Register("reverse", withoutArg(Reverse))
+	Register("timestamp", withoutArg(Timestamp))
+	Register("json", withoutArg(JSON))
+	Register("prefix", func(arg string) (Responder, error) {
+		return Prefix(arg), nil
+	})
+	Register("static", func(arg string) (Responder, error) {
+		if arg == "" {
+			return nil, serrors.New("missing message, e.g. static:hello")
+		}
+		return Static(arg), nil
+	})
+}
+
+// withoutArg returns a NewResponderFunc for a responder without argument.
+func withoutArg(r Responder) NewResponderFunc {
+	return func(arg string) (Responder, error) {
+		if arg != "" {
+			return nil, serrors.New("unexpected argument", "arg", arg)
+		}
+		return r, nil
+	}
+}
@@ -0,0 +1,97 @@
+package echo_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/snet"
+
+	"github.com/tzaeschke/scion-hello/echo"
+)
+
+func TestResponders(t *testing.T) {
+	want := []string{"echo", "json", "prefix", "reverse", "static", "timestamp"}
+	if got := echo.Responders(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Responders() = %v, want %v", got, want)
+	}
+}
+
+func TestNewResponder(t *testing.T) {
+	received := time.Date(2023, 8, 1, 12, 0, 0, 42, time.UTC)
+	req := &echo.Request{
+		Source:      mustUDPAddr(t, "1-ff00:0:111,127.0.0.2:40000"),
+		Destination: mustUDPAddr(t, "1-ff00:0:110,127.0.0.1:8080"),
+		Payload:     []byte("hello"),
+		Underlay:    lastHop,
+		Received:    received,
+	}
+	diagnostics, err := json.Marshal(echo.Diagnostics{
+		Source:      "1-ff00:0:111,127.0.0.2:40000",
+		Destination: "1-ff00:0:110,127.0.0.1:8080",
+		Underlay:    lastHop.String(),
+		Length:      5,
+		Received:    received,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		// want is the reply to req, nil if the specification is invalid.
+		want []byte
+	}{
+		"echo":                     {want: []byte("hello")},
+		"reverse":                  {want: []byte("olleh")},
+		"timestamp":                {want: []byte("2023-08-01T12:00:00.000000042Z")},
+		"json":                     {want: diagnostics},
+		"prefix:Re: ":              {want: []byte("Re: hello")},
+		"prefix":                   {want: []byte("hello")},
+		"static:!DLROW ,OLLEh":     {want: []byte("!DLROW ,OLLEh")},
+		"static:with:colon":        {want: []byte("with:colon")},
+		"static":                   {},
+		"static:":                  {},
+		"echo:unexpected":          {},
+		"reverse:unexpected":       {},
+		"timestamp:unexpected":     {},
+		"json:unexpected":          {},
+		"unknown":                  {},
+		"unknown:with argument":    {},
+		"":                         {},
+		":missing name":            {},
+		"ECHO":                     {},
+		" echo":                    {},
+		"prefix::double separator": {want: []byte(":double separatorhello")},
+	}
+	for spec, tc := range tests {
+		t.Run(spec, func(t *testing.T) {
+			r, err := echo.NewResponder(spec)
+			if tc.want == nil {
+				if err == nil {
+					t.Errorf("NewResponder(%q) succeeded, want error", spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := r.Respond(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(tc.want) {
+				t.Errorf("reply = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func mustUDPAddr(t *testing.T, s string) snet.UDPAddr {
+	t.Helper()
+	a, err := snet.ParseUDPAddr(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *a
+}
@@ -0,0 +1,449 @@
+// Package echo implements the hello echo server. The server answers every UDP packet over the
+// reversed path, see package replypath for the supported path types, or over a path of its own
+// choice. By default, the reply has the same payload; a Responder can compute other replies,
+// see NewResponder for the built-in ones.
+//
+// The server can listen on several connections, e.g. an IPv4 and an IPv6 one. One reader per
+// connection feeds the received packets to a shared pool of workers that answer them over the
+// connection the packet arrived on. Each connection can have its own Responder. Failures of
+// a single packet, e.g. an unexpected payload or path type, are logged and counted but never
+// stop the server.
+//
+// Requests starting with a message Header are answered with the same header in front of the
+// reply, so clients can match replies and measure round trip times, see Tracker.
+//
+// Requests created with WithTrace are handled in a child span of the client's span, so the
+// server side shows up in the trace of the client.
+//
+// To prevent abuse as a reflector, the UDP payload of a reply is never larger than the one of
+// its request. Only the payload is compared, as the reply path may be longer than the request
+// path, e.g. if a replypath.Lookup chooses it. Requests padded with WithPadding leave room for
+// longer replies, the server strips the padding before it computes the reply. Limits can
+// restrict the answered sources further:
+//
+//	srv := &echo.Server{Workers: 8, Limits: echo.Limits{PerHost: echo.Rate{PerSecond: 10}}}
+//	err := srv.Serve(ctx, conn4, conn6)
+package echo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/metrics"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+	"github.com/scionproto/scion/private/tracing"
+
+	"github.com/tzaeschke/scion-hello/replypath"
+)
+
+// DefaultDrainTimeout is the time a stopping server waits for the pending packets by default.
+const DefaultDrainTimeout = 5 * time.Second
+
+// The pause after a failed read doubles with every consecutive failure, from minReadBackoff up
+// to maxReadBackoff, so that a persistent error does not make the reader spin.
+const (
+	minReadBackoff = time.Millisecond
+	maxReadBackoff = time.Second
+)
+
+// Stats are the packet counters of a Server.
+type Stats struct {
+	// Received is the number of packets read from the connection.
+	Received uint64
+	// Replied is the number of replies sent.
+	Replied uint64
+	// Failed is the number of packets that could not be read or answered.
+	Failed uint64
+	// Denied is the number of packets dropped because the source ISD-AS is not allowed.
+	Denied uint64
+	// Limited is the number of packets dropped because of the rate limits.
+	Limited uint64
+	// Oversized is the number of packets dropped because the UDP payload of the reply would
+	// have been larger than the one of the request.
+	Oversized uint64
+}
+
+// Dropped returns the number of packets that were dropped on purpose.
+func (s Stats) Dropped() uint64 {
+	return s.Denied + s.Limited + s.Oversized
+}
+
+// errOversized is returned by handle if the UDP payload of the reply would be larger than the
+// one of the request.
+var errOversized = serrors.New("reply payload larger than request payload")
+
+// Server is an echo server. The zero value is ready to use.
+type Server struct {
+	// Workers is the number of goroutines answering packets. If 0, it is the number of CPUs.
+	Workers int
+	// DrainTimeout is the time Serve waits for the workers to answer the pending packets
+	// after ctx is done. If 0, DefaultDrainTimeout is used.
+	DrainTimeout time.Duration
+	// Limits restrict the sources that are answered. The zero value answers all sources.
+	Limits Limits
+	// Responder computes the replies of listeners without responder. If nil, Echo is used.
+	Responder Responder
+	// Logger is used for per-packet messages. If nil, the root logger is used.
+	Logger log.Logger
+	// Tracer creates the spans of traced requests. If nil, the global tracer is used.
+	Tracer opentracing.Tracer
+	// Metrics are updated for every request in addition to the counters of Stats.
+	Metrics Metrics
+	// Sessions records the clients of the server. If nil, they are not recorded.
+	Sessions *Sessions
+	// ReplyPaths chooses the paths of the replies, e.g. a replypath.Lookup. If nil, the
+	// replies are sent over the reversed path, and requests received over an EPIC path are
+	// answered over the reversed SCION path.
+	ReplyPaths replypath.Pather
+
+	limiter limiter
+
+	received  atomic.Uint64
+	replied   atomic.Uint64
+	failed    atomic.Uint64
+	denied    atomic.Uint64
+	limited   atomic.Uint64
+	oversized atomic.Uint64
+}
+
+// Listener is a connection served with a specific Responder.
+type Listener struct {
+	Conn snet.PacketConn
+	// Responder computes the replies. If nil, the Responder of the server is used.
+	Responder Responder
+}
+
+// request is a received packet waiting for an answer.
+type request struct {
+	pkt snet.Packet
+	ov  net.UDPAddr
+	// l is the listener the packet arrived on.
+	l        *Listener
+	received time.Time
+}
+
+// requests recycles requests, including the packet buffers.
+var requests = sync.Pool{
+	New: func() interface{} { return new(request) },
+}
+
+// Serve reads packets from conns and answers them until ctx is done or one of the connections
+// fails permanently, e.g. because it was closed. Once reading stopped, Serve waits up to
+// DrainTimeout for the pending packets to be answered. It returns ctx.Err() if ctx is done.
+func (s *Server) Serve(ctx context.Context, conns ...snet.PacketConn) error {
+	listeners := make([]Listener, 0, len(conns))
+	for _, conn := range conns {
+		listeners = append(listeners, Listener{Conn: conn})
+	}
+	return s.ServeListeners(ctx, listeners...)
+}
+
+// ServeListeners is like Serve, with a Responder per connection.
+func (s *Server) ServeListeners(ctx context.Context, listeners ...Listener) error {
+	if len(listeners) == 0 {
+		return serrors.New("no connection to serve")
+	}
+	logger := s.logger()
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for i := range listeners {
+		conn := listeners[i].Conn
+		// Unblock the pending read once reading stops.
+		stop := context.AfterFunc(readCtx, func() {
+			if err := conn.SetReadDeadline(time.Now()); err != nil {
+				logger.Error("Failed to interrupt read", "err", err)
+			}
+		})
+		defer stop()
+	}
+
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	queue := make(chan *request, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer log.HandlePanic()
+			defer wg.Done()
+			for r := range queue {
+				s.answer(r)
+			}
+		}()
+	}
+
+	// The first error is the one that stopped reading; the others are caused by the stop.
+	errs := make(chan error, len(listeners))
+	var readers sync.WaitGroup
+	for i := range listeners {
+		readers.Add(1)
+		go func(l *Listener) {
+			defer log.HandlePanic()
+			defer readers.Done()
+			errs <- s.read(readCtx, l, queue)
+			cancel()
+		}(&listeners[i])
+	}
+	readers.Wait()
+	close(queue)
+	if err := s.drain(&wg); err != nil {
+		logger.Error("Stopped with unanswered packets", "err", err)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return <-errs
+}
+
+// read feeds the packets read from listener l to queue.
+func (s *Server) read(ctx context.Context, l *Listener, queue chan<- *request) error {
+	logger := s.logger()
+	var backoff time.Duration
+	for {
+		r := requests.Get().(*request)
+		r.l = l
+		if err := l.Conn.ReadFrom(&r.pkt, &r.ov); err != nil {
+			requests.Put(r)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return serrors.WrapStr("reading packet", err)
+			}
+			s.failed.Add(1)
+			backoff = min(max(2*backoff, minReadBackoff), maxReadBackoff)
+			logger.Error("Failed to read packet", "err", err, "retry_in", backoff)
+			t := time.NewTimer(backoff)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			}
+			continue
+		}
+		backoff = 0
+		r.received = time.Now()
+		s.received.Add(1)
+		queue <- r
+	}
+}
+
+// answer answers request r and recycles it.
+func (s *Server) answer(r *request) {
+	defer requests.Put(r)
+	if !s.admit(&r.pkt) {
+		return
+	}
+	if err := s.handle(r); err != nil {
+		if errors.Is(err, errOversized) {
+			s.oversized.Add(1)
+			metrics.CounterInc(s.Metrics.Oversized)
+			s.logger().Info("Dropped packet", "src", r.pkt.Source, "err", err)
+			return
+		}
+		s.failed.Add(1)
+		s.logger().Error("Failed to answer packet", "src", r.pkt.Source, "err", err)
+		return
+	}
+	s.replied.Add(1)
+}
+
+// admit checks packet p against the limits and counts it if it is dropped.
+func (s *Server) admit(p *snet.Packet) bool {
+	if !s.Limits.allowed(p.Source.IA) {
+		s.denied.Add(1)
+		s.logger().Debug("Dropped packet from denied ISD-AS", "src", p.Source)
+		return false
+	}
+	now := time.Now()
+	if !s.limiter.allow(s.Limits, p.Source.IA.String(), p.Source.String(), now) {
+		s.limited.Add(1)
+		s.logger().Debug("Dropped packet over rate limit", "src", p.Source)
+		return false
+	}
+	return true
+}
+
+// drain waits up to DrainTimeout for the workers to finish.
+func (s *Server) drain(wg *sync.WaitGroup) error {
+	timeout := s.DrainTimeout
+	if timeout == 0 {
+		timeout = DefaultDrainTimeout
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return serrors.New("timeout answering pending packets", "timeout", timeout)
+	}
+}
+
+// Stats returns a snapshot of the packet counters.
+func (s *Server) Stats() Stats {
+	return Stats{
+		Received:  s.received.Load(),
+		Replied:   s.replied.Load(),
+		Failed:    s.failed.Load(),
+		Denied:    s.denied.Load(),
+		Limited:   s.limited.Load(),
+		Oversized: s.oversized.Load(),
+	}
+}
+
+// handle answers request r.
+func (s *Server) handle(r *request) (err error) {
+	p, ov := &r.pkt, &r.ov
+	udp, ok := p.Payload.(snet.UDPPayload)
+	if !ok {
+		metrics.CounterInc(s.Metrics.DecodeFailures)
+		return serrors.New("unexpected payload", "type", common.TypeOf(p.Payload))
+	}
+	// The fingerprint of the raw path is only computed if it is needed.
+	var labels []string
+	if s.Metrics.Handled != nil || s.Metrics.Replies != nil ||
+		s.Metrics.ReversalFailures != nil || s.Sessions != nil {
+
+		fingerprint := "unknown"
+		if rp, ok := p.Path.(snet.RawPath); ok {
+			fingerprint = PathFingerprint(rp.PathType, rp.Raw)
+		}
+		labels = s.Metrics.labels(p.Source.IA, fingerprint)
+		if s.Sessions != nil {
+			s.Sessions.Record(UDPAddr(p.Source, udp.SrcPort), fingerprint, len(udp.Payload),
+				r.received)
+		}
+	}
+	trace, msg, traced := SplitTrace(udp.Payload)
+	msg, _ = SplitPadding(msg)
+	span := s.startSpan(trace, traced)
+	span.SetTag("src", p.Source)
+	defer func() {
+		tracing.Error(span, err)
+		span.Finish()
+	}()
+	logger := s.logger()
+	hdr, msg, err := ParseHeader(msg)
+	if err != nil && !errors.Is(err, ErrNoHeader) {
+		metrics.CounterInc(s.Metrics.DecodeFailures)
+		return serrors.WrapStr("parsing message header", err)
+	}
+	var hdrp *Header
+	if err == nil {
+		hdrp = &hdr
+	}
+	logger.Debug("Received message", "src", p.Source, "port", udp.SrcPort,
+		"message", string(msg))
+	metrics.CounterInc(metrics.CounterWith(s.Metrics.Handled, labels...))
+
+	// Choosing the reply path has a span of its own. A replypath.Lookup queries the daemon in
+	// the background, so the span does not include the query.
+	pathSpan, pathCtx := opentracing.StartSpanFromContextWithTracer(
+		opentracing.ContextWithSpan(context.Background(), span), span.Tracer(), "echo.reply_path")
+	replyPath, nextHop, err := s.replyPaths().ReplyPath(pathCtx, p.Source.IA, p.Path, ov)
+	tracing.Error(pathSpan, err)
+	pathSpan.Finish()
+	if err != nil {
+		if s.Metrics.ReversalFailures != nil {
+			// Only the remote ISD-AS label, bounded by Metrics.Labels like the others.
+			metrics.CounterInc(s.Metrics.ReversalFailures.With(labels[:2]...))
+		}
+		return serrors.WrapStr("creating reply path", err)
+	}
+
+	reply, err := s.responder(r.l).Respond(&Request{
+		Source:      UDPAddr(p.Source, udp.SrcPort),
+		Destination: UDPAddr(p.Destination, udp.DstPort),
+		Header:      hdrp,
+		Payload:     msg,
+		Underlay:    ov,
+		Received:    r.received,
+	})
+	if err != nil {
+		return serrors.WrapStr("computing reply", err)
+	}
+	if hdrp != nil {
+		reply = append(hdr.AppendTo(make([]byte, 0, HeaderLen+len(reply))), reply...)
+	}
+	if traced {
+		if reply, err = WithTrace(trace, reply); err != nil {
+			return err
+		}
+	}
+
+	if len(reply) > len(udp.Payload) {
+		return serrors.WithCtx(errOversized, "request", len(udp.Payload), "reply", len(reply))
+	}
+
+	p.Destination, p.Source = p.Source, p.Destination
+	p.Path = replyPath
+	p.Payload = snet.UDPPayload{
+		DstPort: udp.SrcPort,
+		SrcPort: udp.DstPort,
+		Payload: reply,
+	}
+	if err := p.Serialize(); err != nil {
+		return serrors.WrapStr("serializing reply", err)
+	}
+	sendSpan := span.Tracer().StartSpan("echo.reply", opentracing.ChildOf(span.Context()))
+	defer sendSpan.Finish()
+	if err := r.l.Conn.WriteTo(p, nextHop); err != nil {
+		tracing.Error(sendSpan, err)
+		return serrors.WrapStr("sending reply", err)
+	}
+	metrics.CounterInc(metrics.CounterWith(s.Metrics.Replies, labels...))
+	logger.Debug("Sent answer", "dst", p.Destination, "bytes", p.Bytes)
+	return nil
+}
+
+func (s *Server) responder(l *Listener) Responder {
+	switch {
+	case l.Responder != nil:
+		return l.Responder
+	case s.Responder != nil:
+		return s.Responder
+	default:
+		return Echo
+	}
+}
+
+// replyPaths returns the Pather of the replies.
+func (s *Server) replyPaths() replypath.Pather {
+	if s.ReplyPaths != nil {
+		return s.ReplyPaths
+	}
+	return replypath.Reverser{}
+}
+
+// UDPAddr returns the UDP address of SCION address a with port. Service addresses have no IP.
+func UDPAddr(a snet.SCIONAddress, port uint16) snet.UDPAddr {
+	host := &net.UDPAddr{Port: int(port)}
+	if a.Host.Type() == addr.HostTypeIP {
+		host.IP, host.Zone = a.Host.IP().AsSlice(), a.Host.IP().Zone()
+	}
+	return snet.UDPAddr{IA: a.IA, Host: host}
+}
+
+func (s *Server) logger() log.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return log.Root()
+}
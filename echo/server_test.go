@@ -0,0 +1,426 @@
+package echo_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/metrics"
+	"github.com/scionproto/scion/pkg/slayers/path"
+	"github.com/scionproto/scion/pkg/slayers/path/scion"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+
+	"github.com/tzaeschke/scion-hello/echo"
+)
+
+var (
+	localIA  = mustParseIA("1-ff00:0:110")
+	clientIP = netip.MustParseAddr("127.0.0.2")
+	serverIP = netip.MustParseAddr("127.0.0.1")
+	// lastHop is the underlay address the fake connection receives all packets from.
+	lastHop = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 9), Port: 30041}
+)
+
+func mustParseIA(s string) addr.IA {
+	ia, err := addr.ParseIA(s)
+	if err != nil {
+		panic(err)
+	}
+	return ia
+}
+
+// fakeConn is an snet.PacketConn that reads the raw packets sent to in, as if they came from
+// lastHop, and records the written packets on out.
+type fakeConn struct {
+	in  chan []byte
+	out chan written
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+// written is a packet written to a fakeConn.
+type written struct {
+	pkt     *snet.Packet
+	nextHop *net.UDPAddr
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		in:      make(chan []byte, 16),
+		out:     make(chan written, 16),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) ReadFrom(pkt *snet.Packet, ov *net.UDPAddr) error {
+	select {
+	case raw := <-c.in:
+		pkt.Prepare()
+		pkt.Bytes = pkt.Bytes[:copy(pkt.Bytes, raw)]
+		*ov = *lastHop
+		return pkt.Decode()
+	case <-c.stopped:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+func (c *fakeConn) WriteTo(pkt *snet.Packet, ov *net.UDPAddr) error {
+	cp := &snet.Packet{Bytes: append(snet.Bytes(nil), pkt.Bytes...)}
+	if err := cp.Decode(); err != nil {
+		return err
+	}
+	c.out <- written{pkt: cp, nextHop: ov}
+	return nil
+}
+
+// SetReadDeadline only supports interrupting the reads, as the server does when it stops.
+func (c *fakeConn) SetReadDeadline(t time.Time) error {
+	if !t.After(time.Now()) {
+		c.stopOnce.Do(func() { close(c.stopped) })
+	}
+	return nil
+}
+
+func (c *fakeConn) SetWriteDeadline(time.Time) error { return nil }
+func (c *fakeConn) SetDeadline(t time.Time) error    { return c.SetReadDeadline(t) }
+func (c *fakeConn) LocalAddr() net.Addr              { return &net.UDPAddr{IP: serverIP.AsSlice()} }
+func (c *fakeConn) Close() error                     { return nil }
+
+// reply waits for the next written packet.
+func (c *fakeConn) reply(t *testing.T) written {
+	t.Helper()
+	select {
+	case w := <-c.out:
+		return w
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reply")
+		return written{}
+	}
+}
+
+// request returns a serialized packet from the client to the server within localIA.
+func request(t *testing.T, pld snet.Payload) []byte {
+	t.Helper()
+	pkt := &snet.Packet{PacketInfo: snet.PacketInfo{
+		Source:      snet.SCIONAddress{IA: localIA, Host: addr.HostIP(clientIP)},
+		Destination: snet.SCIONAddress{IA: localIA, Host: addr.HostIP(serverIP)},
+		Path:        snetpath.Empty{},
+		Payload:     pld,
+	}}
+	if err := pkt.Serialize(); err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte(nil), pkt.Bytes...)
+}
+
+// udpRequest returns a serialized UDP packet with payload msg.
+func udpRequest(t *testing.T, msg []byte) []byte {
+	t.Helper()
+	return request(t, snet.UDPPayload{SrcPort: 40000, DstPort: 8080, Payload: msg})
+}
+
+// serve runs srv on conn until the returned function is called, which returns the error
+// of Serve.
+func serve(t *testing.T, srv *echo.Server, conn *fakeConn) func() error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, conn) }()
+	return func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("server did not stop")
+			return nil
+		}
+	}
+}
+
+func TestServerSurvivesMalformedPackets(t *testing.T) {
+	conn := newFakeConn()
+	srv := &echo.Server{Workers: 1}
+	stop := serve(t, srv, conn)
+
+	// Not a SCION packet at all: reading fails.
+	conn.in <- []byte("not a SCION packet")
+	// A valid SCION packet without UDP payload: answering fails.
+	conn.in <- request(t, snet.SCMPEchoRequest{Identifier: 1, SeqNumber: 1})
+	conn.in <- udpRequest(t, []byte("hello"))
+
+	w := conn.reply(t)
+	udp, ok := w.pkt.Payload.(snet.UDPPayload)
+	if !ok {
+		t.Fatalf("reply payload is %T, want snet.UDPPayload", w.pkt.Payload)
+	}
+	if string(udp.Payload) != "hello" {
+		t.Errorf("reply = %q, want %q", udp.Payload, "hello")
+	}
+	if udp.DstPort != 40000 || udp.SrcPort != 8080 {
+		t.Errorf("reply ports = %d->%d, want 8080->40000", udp.SrcPort, udp.DstPort)
+	}
+	if got := w.pkt.Destination.Host.IP(); got != clientIP {
+		t.Errorf("reply destination = %v, want %v", got, clientIP)
+	}
+	if w.nextHop.String() != lastHop.String() {
+		t.Errorf("next hop = %v, want %v", w.nextHop, lastHop)
+	}
+
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Serve() = %v, want %v", err, context.Canceled)
+	}
+	want := echo.Stats{Received: 2, Replied: 1, Failed: 2}
+	if got := srv.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+// failingConn is a fakeConn whose reads fail until it is stopped.
+type failingConn struct {
+	*fakeConn
+}
+
+func (c failingConn) ReadFrom(*snet.Packet, *net.UDPAddr) error {
+	select {
+	case <-c.stopped:
+		return os.ErrDeadlineExceeded
+	default:
+		return errors.New("persistent read error")
+	}
+}
+
+func TestServerBacksOffOnReadErrors(t *testing.T) {
+	conn := failingConn{newFakeConn()}
+	srv := &echo.Server{Workers: 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, conn) }()
+
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Serve() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server did not stop during the backoff")
+	}
+	// Without backoff, the reader would have failed millions of times.
+	if failed := srv.Stats().Failed; failed == 0 || failed > 20 {
+		t.Errorf("Failed = %d, want a few failures", failed)
+	}
+}
+
+// noReply checks that no packet is written for a while.
+func (c *fakeConn) noReply(t *testing.T) {
+	t.Helper()
+	select {
+	case w := <-c.out:
+		t.Fatalf("unexpected reply %v", w.pkt.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestServerRepliesOfPaddedRequests(t *testing.T) {
+	tests := map[string]string{
+		"echo":                                   "hello",
+		"reverse":                                "olleh",
+		"prefix:Re":                              "Rehello",
+		"static:a longer reply than the message": "a longer reply than the message",
+		"timestamp":                              "",
+		"json":                                   "",
+	}
+	for spec, want := range tests {
+		t.Run(spec, func(t *testing.T) {
+			r, err := echo.NewResponder(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn := newFakeConn()
+			srv := &echo.Server{Workers: 1, Responder: r}
+			stop := serve(t, srv, conn)
+			defer stop()
+
+			padded, err := echo.WithPadding(256, []byte("hello"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.in <- udpRequest(t, padded)
+			w := conn.reply(t)
+			reply := w.pkt.Payload.(snet.UDPPayload).Payload
+			if want != "" && string(reply) != want {
+				t.Errorf("reply = %q, want %q", reply, want)
+			}
+			if len(reply) == 0 {
+				t.Error("empty reply")
+			}
+		})
+	}
+}
+
+func TestServerDropsOversizedReplies(t *testing.T) {
+	oversized := &counter{rec: &record{}}
+	conn := newFakeConn()
+	srv := &echo.Server{
+		Workers:   1,
+		Responder: echo.Timestamp,
+		Metrics:   echo.Metrics{Oversized: oversized},
+	}
+	stop := serve(t, srv, conn)
+
+	conn.in <- udpRequest(t, []byte("hello"))
+	conn.noReply(t)
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Serve() = %v, want %v", err, context.Canceled)
+	}
+	want := echo.Stats{Received: 1, Oversized: 1}
+	if got := srv.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if got := len(oversized.rec.incs); got != 1 {
+		t.Errorf("oversized counter incremented %d times, want 1", got)
+	}
+}
+
+func TestServerDropsRepliesLargerThanPaddedRequests(t *testing.T) {
+	long := make([]byte, 64)
+	for i := range long {
+		long[i] = 'x'
+	}
+	tests := map[string]struct {
+		padding int
+		replied bool
+	}{
+		"padding too short": {padding: 16},
+		"padding fits":      {padding: 64, replied: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn := newFakeConn()
+			srv := &echo.Server{Workers: 1, Responder: echo.Static(string(long))}
+			stop := serve(t, srv, conn)
+
+			padded, err := echo.WithPadding(tc.padding, []byte("hello"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.in <- udpRequest(t, padded)
+			want := echo.Stats{Received: 1, Oversized: 1}
+			if tc.replied {
+				conn.reply(t)
+				want = echo.Stats{Received: 1, Replied: 1}
+			} else {
+				conn.noReply(t)
+			}
+			stop()
+			if got := srv.Stats(); got != want {
+				t.Errorf("Stats() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// longPather chooses a reply path that is longer than the empty request path.
+type longPather struct{}
+
+func (longPather) ReplyPath(_ context.Context, _ addr.IA, _ snet.DataplanePath,
+	nextHop *net.UDPAddr) (snet.DataplanePath, *net.UDPAddr, error) {
+
+	dec := scion.Decoded{
+		Base: scion.Base{
+			PathMeta: scion.MetaHdr{SegLen: [3]uint8{2}},
+			NumINF:   1,
+			NumHops:  2,
+		},
+		InfoFields: []path.InfoField{{ConsDir: true}},
+		HopFields:  []path.HopField{{ConsEgress: 1}, {ConsIngress: 2}},
+	}
+	raw := make([]byte, dec.Len())
+	if err := dec.SerializeTo(raw); err != nil {
+		return nil, nil, err
+	}
+	return snetpath.SCION{Raw: raw}, nextHop, nil
+}
+
+func TestServerRepliesOverLongerPaths(t *testing.T) {
+	conn := newFakeConn()
+	srv := &echo.Server{Workers: 1, ReplyPaths: longPather{}}
+	stop := serve(t, srv, conn)
+
+	conn.in <- udpRequest(t, []byte("hello"))
+	w := conn.reply(t)
+	stop()
+	if reply := w.pkt.Payload.(snet.UDPPayload).Payload; string(reply) != "hello" {
+		t.Errorf("reply = %q, want %q", reply, "hello")
+	}
+	if got := srv.Stats().Oversized; got != 0 {
+		t.Errorf("%d oversized replies, want 0", got)
+	}
+}
+
+// counter is a metrics.Counter recording the label values it is incremented with.
+type counter struct {
+	labels []string
+	rec    *record
+}
+
+// record are the label values of the increments of a counter and its labeled counters.
+type record struct {
+	mu   sync.Mutex
+	incs [][]string
+}
+
+func (c *counter) With(labels ...string) metrics.Counter {
+	return &counter{labels: append(c.labels[:len(c.labels):len(c.labels)], labels...),
+		rec: c.rec}
+}
+
+func (c *counter) Add(float64) {
+	c.rec.mu.Lock()
+	defer c.rec.mu.Unlock()
+	c.rec.incs = append(c.rec.incs, c.labels)
+}
+
+// failingPather fails to choose any reply path.
+type failingPather struct{}
+
+func (failingPather) ReplyPath(context.Context, addr.IA, snet.DataplanePath,
+	*net.UDPAddr) (snet.DataplanePath, *net.UDPAddr, error) {
+
+	return nil, nil, errors.New("no reply path")
+}
+
+func TestServerBoundsReversalFailureLabels(t *testing.T) {
+	failures := &counter{rec: &record{}}
+	conn := newFakeConn()
+	srv := &echo.Server{
+		Workers:    1,
+		ReplyPaths: failingPather{},
+		Metrics: echo.Metrics{
+			ReversalFailures: failures,
+			Labels: func(addr.IA, string) (string, string) {
+				return "other", "other"
+			},
+		},
+	}
+	stop := serve(t, srv, conn)
+	conn.in <- udpRequest(t, []byte("hello"))
+	conn.noReply(t)
+	stop()
+
+	want := [][]string{{echo.LabelRemoteIA, "other"}}
+	if got := failures.rec.incs; !reflect.DeepEqual(got, want) {
+		t.Errorf("reversal failures labeled %v, want %v", got, want)
+	}
+}
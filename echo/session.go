@@ -0,0 +1,149 @@
+package echo
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+)
+
+const (
+	// DefaultSessionIdle is the time after which an idle session expires by default.
+	DefaultSessionIdle = 5 * time.Minute
+	// maxSessions is the number of sessions a table keeps. Further clients are not recorded
+	// until sessions expire.
+	maxSessions = 1 << 16
+	// maxSessionPaths is the number of paths a session keeps. Further paths of the client are
+	// not recorded.
+	maxSessionPaths = 64
+)
+
+// Session is what the server knows about a client, i.e. a source ISD-AS, host and port.
+type Session struct {
+	Source    string    `json:"source"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Packets   uint64    `json:"packets"`
+	Bytes     uint64    `json:"bytes"`
+	// Paths are the fingerprints of the paths the client used, see PathFingerprint. At most
+	// 64 paths are recorded per session.
+	Paths []string `json:"paths"`
+}
+
+// Sessions is a table of the clients of a server. Sessions expire once they were idle for
+// IdleTimeout. Sessions is an http.Handler serving the table as JSON. It is safe for
+// concurrent use.
+type Sessions struct {
+	// IdleTimeout is the time after which an idle session expires. If 0,
+	// DefaultSessionIdle is used.
+	IdleTimeout time.Duration
+
+	mu         sync.Mutex
+	sessions   map[string]*session
+	lastExpire time.Time
+}
+
+type session struct {
+	Session
+	ia    addr.IA
+	paths map[string]struct{}
+}
+
+// Record records a packet of n bytes from src over the path with fingerprint at now.
+// Concurrent calls may pass their times out of order, so a session is only ever seen later.
+func (t *Sessions) Record(src snet.UDPAddr, fingerprint string, n int, now time.Time) {
+	key := src.String()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.lastExpire) > time.Second {
+		t.expire(now)
+	}
+	s, ok := t.sessions[key]
+	if !ok {
+		if len(t.sessions) >= maxSessions {
+			return
+		}
+		if t.sessions == nil {
+			t.sessions = make(map[string]*session)
+		}
+		s = &session{
+			Session: Session{Source: key, FirstSeen: now},
+			ia:      src.IA,
+			paths:   make(map[string]struct{}),
+		}
+		t.sessions[key] = s
+	}
+	if now.After(s.LastSeen) {
+		s.LastSeen = now
+	}
+	if now.Before(s.FirstSeen) {
+		s.FirstSeen = now
+	}
+	s.Packets++
+	s.Bytes += uint64(n)
+	if len(s.paths) < maxSessionPaths {
+		s.paths[fingerprint] = struct{}{}
+	}
+}
+
+// Snapshot returns the sessions that are not expired at now, the most recently active first.
+// If ia is not zero, only the sessions of clients in ia are returned.
+func (t *Sessions) Snapshot(ia addr.IA, now time.Time) []Session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expire(now)
+	sessions := make([]Session, 0, len(t.sessions))
+	for _, s := range t.sessions {
+		if !ia.IsZero() && !ia.Equal(s.ia) {
+			continue
+		}
+		c := s.Session
+		c.Paths = make([]string, 0, len(s.paths))
+		for p := range s.paths {
+			c.Paths = append(c.Paths, p)
+		}
+		sort.Strings(c.Paths)
+		sessions = append(sessions, c)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	return sessions
+}
+
+// ServeHTTP writes the snapshot of the table as JSON array. The query parameter ia selects
+// the sessions of an ISD-AS, e.g. /sessions?ia=1-ff00:0:110.
+func (t *Sessions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var ia addr.IA
+	if s := r.URL.Query().Get("ia"); s != "" {
+		var err error
+		if ia, err = addr.ParseIA(s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(t.Snapshot(ia, time.Now())); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// expire removes the idle sessions. The caller must hold the lock.
+func (t *Sessions) expire(now time.Time) {
+	idle := t.IdleTimeout
+	if idle == 0 {
+		idle = DefaultSessionIdle
+	}
+	for key, s := range t.sessions {
+		if now.Sub(s.LastSeen) > idle {
+			delete(t.sessions, key)
+		}
+	}
+	t.lastExpire = now
+}
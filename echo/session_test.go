@@ -0,0 +1,148 @@
+package echo
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+)
+
+func sessionSource(t *testing.T, ia string, port int) snet.UDPAddr {
+	t.Helper()
+	parsed, err := addr.ParseIA(ia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snet.UDPAddr{IA: parsed, Host: &net.UDPAddr{IP: net.IP{127, 0, 0, 2}, Port: port}}
+}
+
+func TestSessionsRecord(t *testing.T) {
+	start := time.Unix(1000, 0)
+	var table Sessions
+	src := sessionSource(t, "1-ff00:0:111", 40000)
+	// A worker that received the packet earlier records it after the later one.
+	table.Record(src, "a", 10, start.Add(2*time.Second))
+	table.Record(src, "b", 20, start.Add(time.Second))
+	table.Record(src, "a", 30, start.Add(3*time.Second))
+	table.Record(src, "a", 40, start)
+
+	got := table.Snapshot(addr.IA(0), start.Add(3*time.Second))
+	if len(got) != 1 {
+		t.Fatalf("Snapshot() = %+v, want one session", got)
+	}
+	s := got[0]
+	if !s.FirstSeen.Equal(start) || !s.LastSeen.Equal(start.Add(3*time.Second)) {
+		t.Errorf("session seen from %v to %v, want from %v to %v", s.FirstSeen, s.LastSeen,
+			start, start.Add(3*time.Second))
+	}
+	if s.Packets != 4 || s.Bytes != 100 {
+		t.Errorf("session has %d packets of %d bytes, want 4 of 100", s.Packets, s.Bytes)
+	}
+	if len(s.Paths) != 2 || s.Paths[0] != "a" || s.Paths[1] != "b" {
+		t.Errorf("Paths = %v, want [a b]", s.Paths)
+	}
+}
+
+func TestSessionsCapPaths(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var table Sessions
+	src := sessionSource(t, "1-ff00:0:111", 40000)
+	for i := 0; i < maxSessionPaths+10; i++ {
+		table.Record(src, strconv.Itoa(i), 1, now)
+	}
+	s := table.Snapshot(addr.IA(0), now)[0]
+	if len(s.Paths) != maxSessionPaths {
+		t.Errorf("session has %d paths, want %d", len(s.Paths), maxSessionPaths)
+	}
+	if s.Packets != maxSessionPaths+10 {
+		t.Errorf("session has %d packets, want %d", s.Packets, maxSessionPaths+10)
+	}
+}
+
+func TestSessionsSnapshot(t *testing.T) {
+	start := time.Unix(1000, 0)
+	table := Sessions{IdleTimeout: 10 * time.Second}
+	table.Record(sessionSource(t, "1-ff00:0:111", 1), "a", 1, start)
+	table.Record(sessionSource(t, "1-ff00:0:111", 2), "a", 1, start.Add(5*time.Second))
+	table.Record(sessionSource(t, "1-ff00:0:112", 3), "a", 1, start.Add(8*time.Second))
+	now := start.Add(12 * time.Second)
+
+	tests := map[string]struct {
+		ia   string
+		want []int
+	}{
+		// The session of port 1 expired.
+		"all":            {want: []int{3, 2}},
+		"by ISD-AS":      {ia: "1-ff00:0:111", want: []int{2}},
+		"other ISD-AS":   {ia: "1-ff00:0:110"},
+		"single session": {ia: "1-ff00:0:112", want: []int{3}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var ia addr.IA
+			if tc.ia != "" {
+				var err error
+				if ia, err = addr.ParseIA(tc.ia); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var ports []int
+			for _, s := range table.Snapshot(ia, now) {
+				a, err := snet.ParseUDPAddr(s.Source)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ports = append(ports, a.Host.Port)
+			}
+			if len(ports) != len(tc.want) {
+				t.Fatalf("sessions of ports %v, want %v", ports, tc.want)
+			}
+			for i := range ports {
+				if ports[i] != tc.want[i] {
+					t.Errorf("sessions of ports %v, want %v", ports, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionsServeHTTP(t *testing.T) {
+	var table Sessions
+	table.Record(sessionSource(t, "1-ff00:0:111", 40000), "a", 1, time.Now())
+
+	tests := map[string]struct {
+		query    string
+		status   int
+		sessions int
+	}{
+		"all":         {query: "", status: http.StatusOK, sessions: 1},
+		"by ISD-AS":   {query: "?ia=1-ff00:0:111", status: http.StatusOK, sessions: 1},
+		"other":       {query: "?ia=1-ff00:0:112", status: http.StatusOK},
+		"invalid ISD": {query: "?ia=ff00:0:111", status: http.StatusBadRequest},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			table.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sessions"+tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d", w.Code, tc.status)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var sessions []Session
+			if err := json.Unmarshal(w.Body.Bytes(), &sessions); err != nil {
+				t.Fatal(err)
+			}
+			if len(sessions) != tc.sessions {
+				t.Errorf("%d sessions, want %d", len(sessions), tc.sessions)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package echo
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/private/tracing"
+)
+
+// traceMagic starts the payloads created by WithTrace.
+var traceMagic = []byte("SCTR")
+
+// WithTrace returns msg prefixed with the binary encoded span context trace, e.g. from
+// tracing.IDFromCtx. The server handles such a payload in a child span of trace, passes only
+// msg to the Responder and prefixes the reply with the same trace. Use SplitTrace to remove
+// it again.
+func WithTrace(trace, msg []byte) ([]byte, error) {
+	if len(trace) > 0xffff {
+		return nil, serrors.New("span context too large", "len", len(trace))
+	}
+	payload := make([]byte, 0, len(traceMagic)+2+len(trace)+len(msg))
+	payload = append(payload, traceMagic...)
+	payload = binary.BigEndian.AppendUint16(payload, uint16(len(trace)))
+	payload = append(payload, trace...)
+	return append(payload, msg...), nil
+}
+
+// SplitTrace splits a payload created by WithTrace into the span context and the message.
+// ok is false if the payload has no span context.
+func SplitTrace(payload []byte) (trace, msg []byte, ok bool) {
+	if !bytes.HasPrefix(payload, traceMagic) || len(payload) < len(traceMagic)+2 {
+		return nil, payload, false
+	}
+	rest := payload[len(traceMagic):]
+	n := int(binary.BigEndian.Uint16(rest))
+	if len(rest) < 2+n {
+		return nil, payload, false
+	}
+	return rest[2 : 2+n], rest[2+n:], true
+}
+
+// startSpan starts the span handling a request with span context trace. Requests without
+// span context are not traced, so every span of the server belongs to a client trace.
+func (s *Server) startSpan(trace []byte, traced bool) opentracing.Span {
+	if !traced {
+		return opentracing.NoopTracer{}.StartSpan("")
+	}
+	tracer := s.Tracer
+	if tracer == nil {
+		tracer = opentracing.GlobalTracer()
+	}
+	parent, err := tracer.Extract(opentracing.Binary, bytes.NewReader(trace))
+	if err != nil {
+		s.logger().Debug("Ignoring invalid span context", "err", err)
+	}
+	span := tracer.StartSpan("echo.handle", ext.RPCServerOption(parent))
+	tracing.Component(span, "echo")
+	return span
+}
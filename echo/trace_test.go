@@ -0,0 +1,99 @@
+package echo_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"github.com/tzaeschke/scion-hello/echo"
+)
+
+// binaryPropagator propagates mock span contexts in the binary format, which the mock tracer
+// does not support by itself.
+type binaryPropagator struct{}
+
+func (binaryPropagator) Inject(sc mocktracer.MockSpanContext, carrier interface{}) error {
+	w, ok := carrier.(io.Writer)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	return binary.Write(w, binary.BigEndian, [2]int64{int64(sc.TraceID), int64(sc.SpanID)})
+}
+
+func (binaryPropagator) Extract(carrier interface{}) (mocktracer.MockSpanContext, error) {
+	r, ok := carrier.(io.Reader)
+	if !ok {
+		return mocktracer.MockSpanContext{}, opentracing.ErrInvalidCarrier
+	}
+	var ids [2]int64
+	if err := binary.Read(r, binary.BigEndian, &ids); err != nil {
+		return mocktracer.MockSpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	return mocktracer.MockSpanContext{TraceID: int(ids[0]), SpanID: int(ids[1]),
+		Sampled: true}, nil
+}
+
+func TestServerTracesRequests(t *testing.T) {
+	tracer := mocktracer.New()
+	tracer.RegisterInjector(opentracing.Binary, binaryPropagator{})
+	tracer.RegisterExtractor(opentracing.Binary, binaryPropagator{})
+
+	client := tracer.StartSpan("client").(*mocktracer.MockSpan)
+	var trace bytes.Buffer
+	if err := tracer.Inject(client.Context(), opentracing.Binary, &trace); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := echo.WithTrace(trace.Bytes(), []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := newFakeConn()
+	srv := &echo.Server{Workers: 1, Tracer: tracer}
+	stop := serve(t, srv, conn)
+	conn.in <- udpRequest(t, payload)
+	w := conn.reply(t)
+	stop()
+	client.Finish()
+
+	// The reply carries the span context of the client as well.
+	gotTrace, msg, ok := echo.SplitTrace(w.pkt.Payload.(snet.UDPPayload).Payload)
+	if !ok || !bytes.Equal(gotTrace, trace.Bytes()) || string(msg) != "hello" {
+		t.Errorf("reply = %q, %q, %v, want the span context and %q", gotTrace, msg, ok, "hello")
+	}
+
+	spans := make(map[string]*mocktracer.MockSpan)
+	for _, s := range tracer.FinishedSpans() {
+		spans[s.OperationName] = s
+	}
+	handle := spans["echo.handle"]
+	if handle == nil {
+		t.Fatalf("no server span, got %v", tracer.FinishedSpans())
+	}
+	if handle.ParentID != client.SpanContext.SpanID ||
+		handle.SpanContext.TraceID != client.SpanContext.TraceID {
+
+		t.Errorf("server span is not a child of the client span: %v", handle)
+	}
+	for _, name := range []string{"echo.reply_path", "echo.reply"} {
+		s := spans[name]
+		if s == nil {
+			t.Errorf("no %s span", name)
+			continue
+		}
+		if s.ParentID != handle.SpanContext.SpanID ||
+			s.SpanContext.TraceID != client.SpanContext.TraceID {
+
+			t.Errorf("%s span is not a child of the server span: %v", name, s)
+		}
+	}
+	if len(spans) != 4 {
+		t.Errorf("got spans %v, want client, echo.handle, echo.reply_path and echo.reply",
+			tracer.FinishedSpans())
+	}
+}
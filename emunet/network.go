@@ -0,0 +1,190 @@
+// Package emunet emulates a SCION network in a single process. It reads a .topo file of the
+// SCION topology generator and starts, for every AS, an emulated border router and a mock
+// daemon serving the paths of the AS over the daemon gRPC API:
+//
+//	topo, err := emunet.LoadTopology("tiny.topo")
+//	n, err := emunet.Start(topo)
+//	defer n.Close()
+//	daemonAddr := n.ASes[ia].DaemonAddr
+//
+// All underlay addresses are on the IPv4 loopback, so end hosts must use IPv4 addresses as
+// well. The routers deliver packets to the UDP destination port, as for dispatcher-less end
+// hosts. Paths are the shortest routes through the topology, encoded as a single segment.
+package emunet
+
+import (
+	"context"
+	"crypto/rand"
+	"net"
+	"sync"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/serrors"
+
+	"github.com/tzaeschke/scion-hello/fakedaemon"
+	"github.com/tzaeschke/scion-hello/router"
+)
+
+// AS is a running emulated AS.
+type AS struct {
+	IA addr.IA
+	// Key is the hop field MAC key of the AS.
+	Key []byte
+	// Router is the border router of the AS, handling all its interfaces.
+	Router *router.Router
+	// RouterAddr is the underlay address of the border router.
+	RouterAddr *net.UDPAddr
+	// Daemon is the daemon of the AS. It records the calls of local end hosts.
+	Daemon *fakedaemon.Connector
+	// DaemonAddr is the address of the daemon gRPC API.
+	DaemonAddr string
+
+	conn *net.UDPConn
+	lis  net.Listener
+}
+
+// Network is a running emulated network.
+type Network struct {
+	// ASes are the emulated ASes.
+	ASes map[addr.IA]*AS
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Start starts the ASes of topo. The network runs until it is closed.
+func Start(topo *Topology) (*Network, error) {
+	n := &Network{ASes: make(map[addr.IA]*AS, len(topo.ASes))}
+	if err := n.listen(topo); err != nil {
+		n.closeListeners()
+		return nil, err
+	}
+	keys := make(map[addr.IA]fakedaemon.AS, len(topo.ASes))
+	for ia, as := range n.ASes {
+		keys[ia] = fakedaemon.AS{MTU: topo.ASes[ia].MTU, Key: as.Key}
+	}
+	for ia, as := range n.ASes {
+		as.Router = &router.Router{
+			IA:         ia,
+			Key:        as.Key,
+			Interfaces: make(map[common.IFIDType]*net.UDPAddr),
+			L4Port:     true,
+			Logger:     log.Root().New("ia", ia),
+		}
+		f := &fakedaemon.Fixture{
+			LocalIA:    ia,
+			ASes:       keys,
+			Interfaces: make(map[common.IFIDType]string),
+		}
+		for _, l := range topo.Links {
+			for _, end := range [][2]LinkEnd{{l.A, l.B}, {l.B, l.A}} {
+				if end[0].IA != ia {
+					continue
+				}
+				as.Router.Interfaces[end[0].ID] = n.ASes[end[1].IA].RouterAddr
+				f.Interfaces[end[0].ID] = as.RouterAddr.String()
+			}
+		}
+		for _, dst := range topo.IAs() {
+			if route := topo.route(ia, dst); route != nil {
+				f.Paths = append(f.Paths, fixturePath(topo, route))
+			}
+		}
+		sd, err := fakedaemon.New(f)
+		if err != nil {
+			n.closeListeners()
+			return nil, serrors.WrapStr("creating daemon", err, "ia", ia)
+		}
+		as.Daemon = sd
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+	for _, as := range n.ASes {
+		as := as
+		n.run(func() error { return as.Router.Serve(ctx, as.conn) })
+		srv := &fakedaemon.Server{Connector: as.Daemon}
+		n.run(func() error { return srv.Serve(ctx, as.lis) })
+	}
+	return n, nil
+}
+
+// Close stops all ASes and waits until they are done.
+func (n *Network) Close() error {
+	n.cancel()
+	n.wg.Wait()
+	n.closeListeners()
+	return nil
+}
+
+// listen opens the router and daemon sockets of all ASes.
+func (n *Network) listen(topo *Topology) error {
+	for _, ia := range topo.IAs() {
+		key := make([]byte, 16)
+		if _, err := rand.Read(key); err != nil {
+			return serrors.WrapStr("generating key", err)
+		}
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			return serrors.WrapStr("listening router", err, "ia", ia)
+		}
+		as := &AS{
+			IA:         ia,
+			Key:        key,
+			RouterAddr: conn.LocalAddr().(*net.UDPAddr),
+			conn:       conn,
+		}
+		n.ASes[ia] = as
+		if as.lis, err = net.Listen("tcp4", "127.0.0.1:0"); err != nil {
+			return serrors.WrapStr("listening daemon", err, "ia", ia)
+		}
+		as.DaemonAddr = as.lis.Addr().String()
+	}
+	return nil
+}
+
+func (n *Network) closeListeners() {
+	for _, as := range n.ASes {
+		as.conn.Close()
+		if as.lis != nil {
+			as.lis.Close()
+		}
+	}
+}
+
+func (n *Network) run(f func() error) {
+	n.wg.Add(1)
+	go func() {
+		defer log.HandlePanic()
+		defer n.wg.Done()
+		if err := f(); err != nil && err != context.Canceled {
+			log.Error("Emulated network component failed", "err", err)
+		}
+	}()
+}
+
+// fixturePath converts a route to a path of the daemon fixture.
+func fixturePath(topo *Topology, route []hop) fakedaemon.Path {
+	src, dst := route[0].From.IA, route[len(route)-1].To.IA
+	p := fakedaemon.Path{
+		Src:  src,
+		Dst:  dst,
+		Hops: []fakedaemon.Hop{{IA: src, Egress: route[0].From.ID}},
+		MTU:  topo.ASes[src].MTU,
+	}
+	for i, h := range route {
+		next := fakedaemon.Hop{IA: h.To.IA, Ingress: h.To.ID}
+		if i+1 < len(route) {
+			next.Egress = route[i+1].From.ID
+			// Bandwidth within the AS is unknown.
+			p.Bandwidth = append(p.Bandwidth, h.Link.Bandwidth, 0)
+		} else {
+			p.Bandwidth = append(p.Bandwidth, h.Link.Bandwidth)
+		}
+		p.Hops = append(p.Hops, next)
+		p.MTU = min(p.MTU, h.Link.MTU, topo.ASes[h.To.IA].MTU)
+	}
+	return p
+}
@@ -0,0 +1,169 @@
+package emunet_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"github.com/tzaeschke/scion-hello/connect"
+	"github.com/tzaeschke/scion-hello/emunet"
+	"github.com/tzaeschke/scion-hello/fakedaemon"
+	"github.com/tzaeschke/scion-hello/replypath"
+)
+
+const (
+	ping = "Hello scion"
+	pong = "Hello back"
+)
+
+var (
+	ia110 = mustParseIA("1-ff00:0:110")
+	ia111 = mustParseIA("1-ff00:0:111")
+	ia112 = mustParseIA("1-ff00:0:112")
+)
+
+func mustParseIA(s string) addr.IA {
+	ia, err := addr.ParseIA(s)
+	if err != nil {
+		panic(err)
+	}
+	return ia
+}
+
+// TestPingPong sends a ping between the leaf ASes of the tiny topology in both directions
+// and expects a pong over the reversed path. Both go through the core AS 110.
+func TestPingPong(t *testing.T) {
+	topo, err := emunet.LoadTopology("testdata/tiny.topo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := emunet.Start(topo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	for _, pair := range [][2]addr.IA{{ia111, ia112}, {ia112, ia111}} {
+		src, dst := pair[0], pair[1]
+		t.Run(src.String()+"->"+dst.String(), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			core := n.ASes[ia110].Router.Stats()
+
+			server := open(ctx, t, n, dst)
+			defer server.Close()
+			done := make(chan error, 1)
+			go func() { done <- handlePing(server.Conn) }()
+
+			client := open(ctx, t, n, src)
+			defer client.Close()
+			paths, err := client.Daemon.Paths(ctx, dst, 0, daemon.PathReqFlags{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(paths) != 1 {
+				t.Fatalf("got %d paths, want 1", len(paths))
+			}
+			if got := len(paths[0].Metadata().Interfaces); got != 4 {
+				t.Errorf("path has %d interfaces, want 4", got)
+			}
+			pkt := &snet.Packet{PacketInfo: snet.PacketInfo{
+				Source:      address(src, client.LocalAddr()),
+				Destination: address(dst, server.LocalAddr()),
+				Path:        paths[0].Dataplane(),
+				Payload: snet.UDPPayload{
+					SrcPort: uint16(client.LocalAddr().Port),
+					DstPort: uint16(server.LocalAddr().Port),
+					Payload: []byte(ping),
+				},
+			}}
+			if err := client.Conn.WriteTo(pkt, paths[0].UnderlayNextHop()); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := client.Conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			var reply snet.Packet
+			var ov net.UDPAddr
+			if err := client.Conn.ReadFrom(&reply, &ov); err != nil {
+				t.Fatalf("no pong: %v", err)
+			}
+			if err := <-done; err != nil {
+				t.Fatalf("handling ping: %v", err)
+			}
+			udp, ok := reply.Payload.(snet.UDPPayload)
+			if !ok || string(udp.Payload) != pong {
+				t.Errorf("reply = %v, want %q", reply.Payload, pong)
+			}
+			if !reply.Source.IA.Equal(dst) {
+				t.Errorf("reply from %v, want %v", reply.Source.IA, dst)
+			}
+			// The core router forwards both the ping and the pong.
+			if got := n.ASes[ia110].Router.Stats().Forwarded - core.Forwarded; got != 2 {
+				t.Errorf("core router forwarded %d packets, want 2", got)
+			}
+			if !calledPaths(n.ASes[src].Daemon.Calls(), dst) {
+				t.Errorf("daemon of %v was not asked for paths to %v", src, dst)
+			}
+		})
+	}
+}
+
+// open opens an end host in AS ia of n, using the daemon of the AS over its gRPC API.
+func open(ctx context.Context, t *testing.T, n *emunet.Network, ia addr.IA) *connect.Host {
+	t.Helper()
+	h, err := connect.Open(ctx, n.ASes[ia].DaemonAddr, &snet.UDPAddr{
+		IA:   ia,
+		Host: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// handlePing answers a single ping with a pong over the reversed path.
+func handlePing(conn snet.PacketConn) error {
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return err
+	}
+	var p snet.Packet
+	var ov net.UDPAddr
+	if err := conn.ReadFrom(&p, &ov); err != nil {
+		return err
+	}
+	udp, ok := p.Payload.(snet.UDPPayload)
+	if !ok || string(udp.Payload) != ping {
+		return serrors.New("unexpected ping", "source", p.Source, "payload", p.Payload)
+	}
+	reversed, err := replypath.Reverser{}.Reverse(p.Path)
+	if err != nil {
+		return err
+	}
+	p.Source, p.Destination = p.Destination, p.Source
+	p.Path = reversed
+	p.Payload = snet.UDPPayload{SrcPort: udp.DstPort, DstPort: udp.SrcPort, Payload: []byte(pong)}
+	return conn.WriteTo(&p, &ov)
+}
+
+func address(ia addr.IA, a *net.UDPAddr) snet.SCIONAddress {
+	ip, _ := netip.AddrFromSlice(a.IP.To4())
+	return snet.SCIONAddress{IA: ia, Host: addr.HostIP(ip)}
+}
+
+func calledPaths(calls []fakedaemon.Call, dst addr.IA) bool {
+	for _, c := range calls {
+		if c.Method == "Paths" && c.Args[0] == dst {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package emunet
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultMTU is the MTU of ASes and links that do not configure one, as in the SCION
+// topology generator.
+const DefaultMTU = 1472
+
+// Topology is a network description in the format of the .topo files of the SCION topology
+// generator. Attributes that do not matter for the emulation, e.g. certificate issuers and
+// underlays, are ignored.
+type Topology struct {
+	// ASes contains the ASes of the network.
+	ASes map[addr.IA]TopoAS
+	// Links are the links between the ASes.
+	Links []Link
+}
+
+// TopoAS is an AS of a Topology.
+type TopoAS struct {
+	Core bool
+	MTU  uint16
+}
+
+// Link is a link between the interfaces A and B.
+type Link struct {
+	A, B LinkEnd
+	// Type is the link type from A to B, e.g. CHILD or CORE.
+	Type string
+	MTU  uint16
+	// Bandwidth is the bandwidth of the link in Kbit/s, 0 if unknown.
+	Bandwidth uint64
+}
+
+// LinkEnd is an interface of an AS.
+type LinkEnd struct {
+	IA addr.IA
+	ID common.IFIDType
+}
+
+type topoFile struct {
+	ASes map[string]struct {
+		Core bool   `yaml:"core"`
+		MTU  uint16 `yaml:"mtu"`
+	} `yaml:"ASes"`
+	Links []struct {
+		A         string `yaml:"a"`
+		B         string `yaml:"b"`
+		LinkAtoB  string `yaml:"linkAtoB"`
+		MTU       uint16 `yaml:"mtu"`
+		Bandwidth uint64 `yaml:"bw"`
+	} `yaml:"links"`
+}
+
+// LoadTopology reads a .topo file.
+func LoadTopology(file string) (*Topology, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, serrors.WrapStr("reading topology", err)
+	}
+	var f topoFile
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, serrors.WrapStr("parsing topology", err, "file", file)
+	}
+	t := &Topology{ASes: make(map[addr.IA]TopoAS, len(f.ASes))}
+	for s, as := range f.ASes {
+		ia, err := addr.ParseIA(s)
+		if err != nil {
+			return nil, serrors.WrapStr("parsing AS", err, "file", file)
+		}
+		mtu := as.MTU
+		if mtu == 0 {
+			mtu = DefaultMTU
+		}
+		t.ASes[ia] = TopoAS{Core: as.Core, MTU: mtu}
+	}
+	for _, l := range f.Links {
+		a, err := parseLinkEnd(l.A)
+		if err != nil {
+			return nil, serrors.WrapStr("parsing link", err, "file", file)
+		}
+		b, err := parseLinkEnd(l.B)
+		if err != nil {
+			return nil, serrors.WrapStr("parsing link", err, "file", file)
+		}
+		for _, end := range []LinkEnd{a, b} {
+			if _, ok := t.ASes[end.IA]; !ok {
+				return nil, serrors.New("link to unknown AS", "file", file, "ia", end.IA)
+			}
+		}
+		mtu := l.MTU
+		if mtu == 0 {
+			mtu = DefaultMTU
+		}
+		t.Links = append(t.Links, Link{
+			A:         a,
+			B:         b,
+			Type:      l.LinkAtoB,
+			MTU:       mtu,
+			Bandwidth: l.Bandwidth,
+		})
+	}
+	return t, nil
+}
+
+// parseLinkEnd parses an interface such as 1-ff00:0:110#1 or 1-ff00:0:120-A#6, where A is
+// the name of the border router.
+func parseLinkEnd(s string) (LinkEnd, error) {
+	as, id, ok := strings.Cut(s, "#")
+	if !ok {
+		return LinkEnd{}, serrors.New("missing interface ID", "interface", s)
+	}
+	if parts := strings.Split(as, "-"); len(parts) == 3 {
+		as = parts[0] + "-" + parts[1]
+	}
+	ia, err := addr.ParseIA(as)
+	if err != nil {
+		return LinkEnd{}, err
+	}
+	ifid, err := strconv.ParseUint(id, 10, 16)
+	if err != nil {
+		return LinkEnd{}, serrors.WrapStr("parsing interface ID", err, "interface", s)
+	}
+	return LinkEnd{IA: ia, ID: common.IFIDType(ifid)}, nil
+}
+
+// IAs returns the ISD-ASes of the topology in ascending order.
+func (t *Topology) IAs() []addr.IA {
+	ias := make([]addr.IA, 0, len(t.ASes))
+	for ia := range t.ASes {
+		ias = append(ias, ia)
+	}
+	sort.Slice(ias, func(i, j int) bool { return ias[i] < ias[j] })
+	return ias
+}
+
+// hop is a link traversal from interface From to interface To.
+type hop struct {
+	From, To LinkEnd
+	Link     *Link
+}
+
+// route returns the shortest sequence of links from src to dst, or nil if dst cannot be
+// reached. The link types (CHILD, CORE, PEER) are ignored: a route may, e.g., go down a
+// CHILD link and up another one, so it is not a valid SCION segment. The emulated routers
+// accept it anyway, as they do not check the link types either.
+func (t *Topology) route(src, dst addr.IA) []hop {
+	neighbors := make(map[addr.IA][]hop)
+	for i := range t.Links {
+		l := &t.Links[i]
+		neighbors[l.A.IA] = append(neighbors[l.A.IA], hop{From: l.A, To: l.B, Link: l})
+		neighbors[l.B.IA] = append(neighbors[l.B.IA], hop{From: l.B, To: l.A, Link: l})
+	}
+	// Breadth-first search, remembering the hop each AS was reached by.
+	prev := map[addr.IA]hop{src: {}}
+	queue := []addr.IA{src}
+	for len(queue) > 0 && queue[0] != dst {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, h := range neighbors[cur] {
+			if _, seen := prev[h.To.IA]; seen {
+				continue
+			}
+			prev[h.To.IA] = h
+			queue = append(queue, h.To.IA)
+		}
+	}
+	if _, ok := prev[dst]; !ok || src == dst {
+		return nil
+	}
+	var hops []hop
+	for ia := dst; ia != src; ia = prev[ia].From.IA {
+		hops = append([]hop{prev[ia]}, hops...)
+	}
+	return hops
+}
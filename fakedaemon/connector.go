@@ -0,0 +1,261 @@
+// Package fakedaemon provides an in-process fake of the SCION daemon. It implements
+// daemon.Connector on top of static paths and AS information loaded from a fixture and
+// records every call, so that code depending on a daemon can run without a SCION network:
+//
+//	sd, err := fakedaemon.Load("testdata/tiny.yaml")
+//	paths, err := sd.Paths(ctx, dst, 0, daemon.PathReqFlags{})
+//	calls := sd.Calls()
+package fakedaemon
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/drkey"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/ctrl/path_mgmt"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+)
+
+// Call is a recorded call of a Connector method.
+type Call struct {
+	// Method is the name of the method, e.g. "Paths".
+	Method string
+	// Args are the arguments of the call, without the context.
+	Args []interface{}
+}
+
+// Connector is a fake daemon.Connector serving the data of a fixture.
+type Connector struct {
+	fixture  *Fixture
+	keys     map[addr.IA][]byte
+	paths    []snet.Path
+	ifs      map[common.IFIDType]*net.UDPAddr
+	services map[addr.SVC][]string
+
+	mu    sync.Mutex
+	calls []Call
+	// used counts the calls answered by each step of the script.
+	used []int
+}
+
+var _ daemon.Connector = (*Connector)(nil)
+
+// Load creates a Connector from the fixture in file.
+func Load(file string) (*Connector, error) {
+	f, err := LoadFixture(file)
+	if err != nil {
+		return nil, err
+	}
+	return New(f)
+}
+
+// New creates a Connector serving f. Path expiry times are relative to the current time.
+func New(f *Fixture) (*Connector, error) {
+	paths, err := f.paths(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	ifs := make(map[common.IFIDType]*net.UDPAddr, len(f.Interfaces))
+	for id, a := range f.Interfaces {
+		u, err := net.ResolveUDPAddr("udp", a)
+		if err != nil {
+			return nil, serrors.WrapStr("parsing interface address", err, "ifid", id)
+		}
+		ifs[id] = u
+	}
+	services := make(map[addr.SVC][]string, len(f.Services))
+	for name, uris := range f.Services {
+		svc, err := addr.ParseSVC(name)
+		if err != nil {
+			return nil, serrors.WrapStr("parsing service", err)
+		}
+		services[svc] = uris
+	}
+	return &Connector{
+		fixture:  f,
+		keys:     f.keys(),
+		paths:    paths,
+		ifs:      ifs,
+		services: services,
+		used:     make([]int, len(f.Script)),
+	}, nil
+}
+
+// Calls returns the calls recorded so far, in call order.
+func (c *Connector) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// Reset clears the recorded calls and restarts the script.
+func (c *Connector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = nil
+	c.used = make([]int, len(c.fixture.Script))
+}
+
+func (c *Connector) record(method string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, Call{Method: method, Args: args})
+}
+
+// LocalIA returns the local IA of the fixture.
+func (c *Connector) LocalIA(ctx context.Context) (addr.IA, error) {
+	c.record("LocalIA")
+	if err := c.step("LocalIA", 0, daemon.PathReqFlags{}).apply(ctx); err != nil {
+		return 0, err
+	}
+	return c.fixture.LocalIA, nil
+}
+
+// Paths returns the fixture paths from src to dst. A zero src is the local IA. If src and
+// dst are equal, a single path with an empty dataplane path is returned.
+func (c *Connector) Paths(ctx context.Context, dst, src addr.IA,
+	f daemon.PathReqFlags) ([]snet.Path, error) {
+
+	c.record("Paths", dst, src, f)
+	step := c.step("Paths", dst, f)
+	if err := step.apply(ctx); err != nil {
+		return nil, err
+	}
+	if src.IsZero() {
+		src = c.fixture.LocalIA
+	}
+	if src.Equal(dst) {
+		return step.paths([]snet.Path{newPath(src, dst, snetpath.Empty{}, nil,
+			snet.PathMetadata{
+				MTU:    c.fixture.ASes[src].MTU,
+				Expiry: time.Now().Add(step.expiry(24 * time.Hour)),
+			})}), nil
+	}
+	var paths []snet.Path
+	for i, p := range c.paths {
+		if !p.Source().Equal(src) || !p.Destination().Equal(dst) {
+			continue
+		}
+		if expiry := step.expiry(0); expiry != 0 {
+			var err error
+			p, err = c.fixture.Paths[i].build(c.fixture, c.keys, time.Now(), expiry)
+			if err != nil {
+				return nil, serrors.WrapStr("building path", err, "index", i)
+			}
+		}
+		paths = append(paths, p)
+	}
+	return step.paths(paths), nil
+}
+
+// ASInfo returns the information about AS ia. A zero ia is the local IA.
+func (c *Connector) ASInfo(ctx context.Context, ia addr.IA) (daemon.ASInfo, error) {
+	c.record("ASInfo", ia)
+	if err := c.step("ASInfo", 0, daemon.PathReqFlags{}).apply(ctx); err != nil {
+		return daemon.ASInfo{}, err
+	}
+	if ia.IsZero() {
+		ia = c.fixture.LocalIA
+	}
+	as, ok := c.fixture.ASes[ia]
+	if !ok {
+		return daemon.ASInfo{}, serrors.New("unknown AS", "ia", ia)
+	}
+	return daemon.ASInfo{IA: ia, MTU: as.MTU}, nil
+}
+
+// IFInfo returns the underlay addresses of the interfaces ifs, or of all interfaces if ifs
+// is empty. Unknown interfaces are omitted.
+func (c *Connector) IFInfo(ctx context.Context,
+	ifs []common.IFIDType) (map[common.IFIDType]*net.UDPAddr, error) {
+
+	c.record("IFInfo", ifs)
+	if err := c.step("IFInfo", 0, daemon.PathReqFlags{}).apply(ctx); err != nil {
+		return nil, err
+	}
+	res := make(map[common.IFIDType]*net.UDPAddr)
+	for id, a := range c.ifs {
+		if len(ifs) == 0 || containsIF(ifs, id) {
+			res[id] = a
+		}
+	}
+	return res, nil
+}
+
+// SVCInfo returns the URIs of the services svcTypes, or of all services if svcTypes is empty.
+func (c *Connector) SVCInfo(ctx context.Context,
+	svcTypes []addr.SVC) (map[addr.SVC][]string, error) {
+
+	c.record("SVCInfo", svcTypes)
+	if err := c.step("SVCInfo", 0, daemon.PathReqFlags{}).apply(ctx); err != nil {
+		return nil, err
+	}
+	res := make(map[addr.SVC][]string)
+	for svc, uris := range c.services {
+		if len(svcTypes) == 0 || containsSVC(svcTypes, svc) {
+			res[svc] = uris
+		}
+	}
+	return res, nil
+}
+
+// RevNotification records the revocation and otherwise ignores it.
+func (c *Connector) RevNotification(ctx context.Context, revInfo *path_mgmt.RevInfo) error {
+	c.record("RevNotification", revInfo)
+	return c.step("RevNotification", 0, daemon.PathReqFlags{}).apply(ctx)
+}
+
+// DRKeyGetASHostKey is not supported.
+func (c *Connector) DRKeyGetASHostKey(ctx context.Context,
+	meta drkey.ASHostMeta) (drkey.ASHostKey, error) {
+
+	c.record("DRKeyGetASHostKey", meta)
+	return drkey.ASHostKey{}, serrors.New("DRKey not supported by fake daemon")
+}
+
+// DRKeyGetHostASKey is not supported.
+func (c *Connector) DRKeyGetHostASKey(ctx context.Context,
+	meta drkey.HostASMeta) (drkey.HostASKey, error) {
+
+	c.record("DRKeyGetHostASKey", meta)
+	return drkey.HostASKey{}, serrors.New("DRKey not supported by fake daemon")
+}
+
+// DRKeyGetHostHostKey is not supported.
+func (c *Connector) DRKeyGetHostHostKey(ctx context.Context,
+	meta drkey.HostHostMeta) (drkey.HostHostKey, error) {
+
+	c.record("DRKeyGetHostHostKey", meta)
+	return drkey.HostHostKey{}, serrors.New("DRKey not supported by fake daemon")
+}
+
+// Close records the call. The connector stays usable.
+func (c *Connector) Close() error {
+	c.record("Close")
+	return nil
+}
+
+func containsIF(ifs []common.IFIDType, id common.IFIDType) bool {
+	for _, i := range ifs {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSVC(svcs []addr.SVC, svc addr.SVC) bool {
+	for _, s := range svcs {
+		if s == svc {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,269 @@
+package fakedaemon_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/ctrl/path_mgmt"
+	"github.com/scionproto/scion/pkg/private/util"
+	"github.com/scionproto/scion/pkg/scrypto"
+	"github.com/scionproto/scion/pkg/slayers/path"
+	"github.com/scionproto/scion/pkg/slayers/path/scion"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+
+	"github.com/tzaeschke/scion-hello/fakedaemon"
+)
+
+var (
+	ia110 = mustParseIA("1-ff00:0:110")
+	ia111 = mustParseIA("1-ff00:0:111")
+	ia112 = mustParseIA("1-ff00:0:112")
+)
+
+func mustParseIA(s string) addr.IA {
+	ia, err := addr.ParseIA(s)
+	if err != nil {
+		panic(err)
+	}
+	return ia
+}
+
+// fixtures are the same fixture in both formats.
+var fixtures = []string{"testdata/tiny.json", "testdata/tiny.yaml"}
+
+func TestConnector(t *testing.T) {
+	for _, file := range fixtures {
+		t.Run(file, func(t *testing.T) {
+			ctx := context.Background()
+			sd, err := fakedaemon.Load(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			local, err := sd.LocalIA(ctx)
+			if err != nil || local != ia111 {
+				t.Errorf("LocalIA() = %v, %v, want %v", local, err, ia111)
+			}
+
+			paths, err := sd.Paths(ctx, ia112, 0, daemon.PathReqFlags{Refresh: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(paths) != 2 {
+				t.Fatalf("Paths() returned %d paths, want 2", len(paths))
+			}
+			checkPath(t, paths[0], "127.0.0.9:31041", []snet.PathInterface{
+				{IA: ia111, ID: 41}, {IA: ia110, ID: 1}, {IA: ia110, ID: 2}, {IA: ia112, ID: 1},
+			})
+			meta := paths[0].Metadata()
+			if meta.MTU != 1280 {
+				t.Errorf("MTU = %d, want 1280", meta.MTU)
+			}
+			wantLatency := []time.Duration{10 * time.Millisecond, 2 * time.Millisecond,
+				5 * time.Millisecond}
+			if !reflect.DeepEqual(meta.Latency, wantLatency) {
+				t.Errorf("Latency = %v, want %v", meta.Latency, wantLatency)
+			}
+			wantLinks := []snet.LinkType{snet.LinkTypeDirect, snet.LinkTypeOpennet}
+			if !reflect.DeepEqual(meta.LinkType, wantLinks) {
+				t.Errorf("LinkType = %v, want %v", meta.LinkType, wantLinks)
+			}
+			if d := time.Until(meta.Expiry); d <= 59*time.Minute || d > time.Hour {
+				t.Errorf("expires in %v, want 1h", d)
+			}
+			// The next hop of the second path is configured explicitly.
+			checkPath(t, paths[1], "127.0.0.10:31043", []snet.PathInterface{
+				{IA: ia111, ID: 43}, {IA: ia112, ID: 3},
+			})
+
+			if paths, err := sd.Paths(ctx, ia110, ia111, daemon.PathReqFlags{}); err != nil ||
+				len(paths) != 0 {
+				t.Errorf("Paths() to AS without path = %v, %v, want none", paths, err)
+			}
+			paths, err = sd.Paths(ctx, ia111, ia111, daemon.PathReqFlags{})
+			if err != nil || len(paths) != 1 || len(paths[0].Metadata().Interfaces) != 0 {
+				t.Errorf("Paths() within AS = %v, %v, want one empty path", paths, err)
+			}
+
+			info, err := sd.ASInfo(ctx, 0)
+			if err != nil || info != (daemon.ASInfo{IA: ia111, MTU: 1472}) {
+				t.Errorf("ASInfo(0) = %+v, %v, want local AS", info, err)
+			}
+			info, err = sd.ASInfo(ctx, ia110)
+			if err != nil || info != (daemon.ASInfo{IA: ia110, MTU: 1400}) {
+				t.Errorf("ASInfo(110) = %+v, %v", info, err)
+			}
+			if _, err := sd.ASInfo(ctx, mustParseIA("2-ff00:0:220")); err == nil {
+				t.Error("ASInfo() of unknown AS succeeded")
+			}
+
+			ifs, err := sd.IFInfo(ctx, nil)
+			if err != nil || len(ifs) != 2 {
+				t.Errorf("IFInfo(nil) = %v, %v, want 2 interfaces", ifs, err)
+			}
+			ifs, err = sd.IFInfo(ctx, []common.IFIDType{43, 99})
+			if err != nil || len(ifs) != 1 || ifs[43].String() != "127.0.0.9:31043" {
+				t.Errorf("IFInfo(43, 99) = %v, %v, want only 43", ifs, err)
+			}
+
+			svcs, err := sd.SVCInfo(ctx, []addr.SVC{addr.SvcDS})
+			wantSVCs := map[addr.SVC][]string{
+				addr.SvcDS: {"127.0.0.12:31001", "127.0.0.13:31001"},
+			}
+			if err != nil || !reflect.DeepEqual(svcs, wantSVCs) {
+				t.Errorf("SVCInfo(DS) = %v, %v, want %v", svcs, err, wantSVCs)
+			}
+			if svcs, err := sd.SVCInfo(ctx, nil); err != nil || len(svcs) != 2 {
+				t.Errorf("SVCInfo(nil) = %v, %v, want CS and DS", svcs, err)
+			}
+
+			rev := &path_mgmt.RevInfo{IfID: 41, RawIsdas: ia111}
+			if err := sd.RevNotification(ctx, rev); err != nil {
+				t.Errorf("RevNotification() = %v", err)
+			}
+
+			var methods []string
+			for _, c := range sd.Calls() {
+				methods = append(methods, c.Method)
+			}
+			wantMethods := []string{"LocalIA", "Paths", "Paths", "Paths", "ASInfo", "ASInfo",
+				"ASInfo", "IFInfo", "IFInfo", "SVCInfo", "SVCInfo", "RevNotification"}
+			if !reflect.DeepEqual(methods, wantMethods) {
+				t.Errorf("recorded calls %v, want %v", methods, wantMethods)
+			}
+			calls := sd.Calls()
+			wantArgs := []interface{}{ia112, addr.IA(0), daemon.PathReqFlags{Refresh: true}}
+			if !reflect.DeepEqual(calls[1].Args, wantArgs) {
+				t.Errorf("Paths call args %v, want %v", calls[1].Args, wantArgs)
+			}
+			if got := calls[len(calls)-1].Args[0]; got != rev {
+				t.Errorf("RevNotification call arg %v, want %v", got, rev)
+			}
+			sd.Reset()
+			if calls := sd.Calls(); len(calls) != 0 {
+				t.Errorf("Calls() after Reset() = %v", calls)
+			}
+		})
+	}
+}
+
+// checkPath checks the next hop and the interfaces of path p.
+func checkPath(t *testing.T, p snet.Path, nextHop string, intfs []snet.PathInterface) {
+	t.Helper()
+	if got := p.UnderlayNextHop(); got == nil || got.String() != nextHop {
+		t.Errorf("UnderlayNextHop() = %v, want %v", got, nextHop)
+	}
+	if got := p.Metadata().Interfaces; !reflect.DeepEqual(got, intfs) {
+		t.Errorf("Interfaces = %v, want %v", got, intfs)
+	}
+}
+
+func TestHopMACs(t *testing.T) {
+	f, err := fakedaemon.LoadFixture("testdata/tiny.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := fakedaemon.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths, err := sd.Paths(context.Background(), ia112, 0, daemon.PathReqFlags{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range paths {
+		dp, ok := p.Dataplane().(snetpath.SCION)
+		if !ok {
+			t.Fatalf("path %d: dataplane path is %T, want snetpath.SCION", i, p.Dataplane())
+		}
+		var dec scion.Decoded
+		if err := dec.DecodeFromBytes(dp.Raw); err != nil {
+			t.Fatalf("path %d: %v", i, err)
+		}
+		if len(dec.InfoFields) != 1 || !dec.InfoFields[0].ConsDir {
+			t.Fatalf("path %d: want a single segment in construction direction", i)
+		}
+		info := dec.InfoFields[0]
+		if i == 0 {
+			checkExpiry(t, dec, p.Metadata().Expiry)
+		}
+		// Verify the MACs like the routers do, chaining each MAC into the SegID of the next
+		// hop.
+		segID := info.SegID
+		hops := f.Paths[i].Hops
+		for j, hf := range dec.HopFields {
+			key := f.ASes[hops[j].IA].Key
+			if got := hopMAC(t, key, segID, info.Timestamp, hf); got != hf.Mac {
+				t.Errorf("path %d, hop %d: MAC %x does not verify with the key of %v",
+					i, j, hf.Mac, hops[j].IA)
+			}
+			if got := hopMAC(t, []byte("wrong key 123456"), segID, info.Timestamp,
+				hf); got == hf.Mac {
+				t.Errorf("path %d, hop %d: MAC verifies with a wrong key", i, j)
+			}
+			segID ^= uint16(hf.Mac[0])<<8 | uint16(hf.Mac[1])
+		}
+	}
+}
+
+func hopMAC(t *testing.T, key []byte, segID uint16, ts uint32,
+	hf path.HopField) [path.MacLen]byte {
+
+	t.Helper()
+	mac, err := scrypto.InitMac(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := path.InfoField{ConsDir: true, SegID: segID, Timestamp: ts}
+	return path.MAC(mac, info, hf, nil)
+}
+
+func TestScriptExpiry(t *testing.T) {
+	for _, expiry := range []time.Duration{-time.Minute, 2 * time.Second, 2 * time.Hour} {
+		t.Run(expiry.String(), func(t *testing.T) {
+			f, err := fakedaemon.LoadFixture("testdata/tiny.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Script = []fakedaemon.Step{{Method: "Paths", Expiry: util.DurWrap{Duration: expiry}}}
+			sd, err := fakedaemon.New(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paths, err := sd.Paths(context.Background(), ia112, 0, daemon.PathReqFlags{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range paths {
+				want := time.Now().Add(expiry)
+				if d := p.Metadata().Expiry.Sub(want); d < -time.Second || d > 0 {
+					t.Errorf("metadata expiry %v, want %v", p.Metadata().Expiry, want)
+				}
+				var dec scion.Decoded
+				if err := dec.DecodeFromBytes(p.Dataplane().(snetpath.SCION).Raw); err != nil {
+					t.Fatal(err)
+				}
+				checkExpiry(t, dec, p.Metadata().Expiry)
+			}
+		})
+	}
+}
+
+// checkExpiry checks that the hop fields of path dec expire at the expiry of the metadata,
+// up to the one second precision of the info field timestamp.
+func checkExpiry(t *testing.T, dec scion.Decoded, expiry time.Time) {
+	t.Helper()
+	ts := time.Unix(int64(dec.InfoFields[0].Timestamp), 0)
+	for j, hf := range dec.HopFields {
+		got := ts.Add(path.ExpTimeToDuration(hf.ExpTime))
+		if d := expiry.Sub(got); d < 0 || d > time.Second {
+			t.Errorf("hop %d expires at %v, want %v", j, got, expiry)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package fakedaemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/private/util"
+	"github.com/scionproto/scion/pkg/snet"
+	"gopkg.in/yaml.v2"
+)
+
+// Fixture is the static data served by a fake daemon. It is usually loaded from a JSON or
+// YAML file:
+//
+//	local_ia: 1-ff00:0:110
+//	ases:
+//	  1-ff00:0:110: {mtu: 1472, key: MTIzNDU2Nzg5MDEyMzQ1Ng==}
+//	interfaces:
+//	  1: 127.0.0.9:31002
+//	services:
+//	  CS: ["127.0.0.11:31000"]
+//	paths:
+//	  - src: 1-ff00:0:110
+//	    dst: 1-ff00:0:112
+//	    mtu: 1472
+//	    expiry: 6h
+//	    latency: [10ms, 5ms, 10ms]
+//	    hops:
+//	      - {ia: 1-ff00:0:110, egress: 1}
+//	      - {ia: 1-ff00:0:111, ingress: 41, egress: 42}
+//	      - {ia: 1-ff00:0:112, ingress: 1}
+type Fixture struct {
+	// LocalIA is the ISD-AS of the local AS.
+	LocalIA addr.IA `json:"local_ia"`
+	// ASes contains the information about the ASes in the fixture. The entry of the local AS
+	// is returned by ASInfo. Keys are used to compute the hop field MACs of paths.
+	ASes map[addr.IA]AS `json:"ases"`
+	// Interfaces maps the interface IDs of the local AS to the underlay address of the border
+	// router owning the interface.
+	Interfaces map[common.IFIDType]string `json:"interfaces"`
+	// Services maps service names (CS, DS) to their addresses.
+	Services map[string][]string `json:"services"`
+	// Paths are the paths between the ASes.
+	Paths []Path `json:"paths"`
+	// Script are the scripted behaviors, see Step.
+	Script []Step `json:"script"`
+}
+
+// AS is the information about an AS.
+type AS struct {
+	// MTU is the MTU within the AS.
+	MTU uint16 `json:"mtu"`
+	// Key is the hop field MAC key of the AS. If empty, MACs are all zero.
+	Key []byte `json:"key"`
+}
+
+// Path is a path from Src to Dst.
+type Path struct {
+	Src addr.IA `json:"src"`
+	Dst addr.IA `json:"dst"`
+	// Hops are the ASes on the path in travel direction.
+	Hops []Hop `json:"hops"`
+	// NextHop is the underlay address of the first border router. It defaults to the address
+	// of the egress interface of the first hop in Interfaces.
+	NextHop string `json:"next_hop"`
+	// MTU is the path MTU.
+	MTU uint16 `json:"mtu"`
+	// Expiry is the lifetime of the path, relative to the time the fixture is loaded.
+	// It defaults to 6h.
+	Expiry util.DurWrap `json:"expiry"`
+	// Latency, Bandwidth, Geo, LinkType, InternalHops and Notes are the path metadata as
+	// described by snet.PathMetadata.
+	Latency      []util.DurWrap `json:"latency"`
+	Bandwidth    []uint64       `json:"bandwidth"`
+	Geo          []Geo          `json:"geo"`
+	LinkType     []string       `json:"link_type"`
+	InternalHops []uint32       `json:"internal_hops"`
+	Notes        []string       `json:"notes"`
+	// EPIC adds EPIC authenticators to the path metadata.
+	EPIC bool `json:"epic"`
+}
+
+// Geo is the location of a border router.
+type Geo struct {
+	Latitude  float32 `json:"latitude"`
+	Longitude float32 `json:"longitude"`
+	Address   string  `json:"address"`
+}
+
+// Hop is an AS on a path. Ingress and Egress are the interfaces in travel direction; the
+// ingress of the first and the egress of the last hop are 0.
+type Hop struct {
+	IA      addr.IA         `json:"ia"`
+	Ingress common.IFIDType `json:"ingress"`
+	Egress  common.IFIDType `json:"egress"`
+}
+
+// LoadFixture reads a fixture from a JSON or YAML file. The format is chosen by the file
+// extension, .json or .yaml/.yml.
+func LoadFixture(file string) (*Fixture, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, serrors.WrapStr("reading fixture", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".json":
+	case ".yaml", ".yml":
+		if raw, err = yamlToJSON(raw); err != nil {
+			return nil, serrors.WrapStr("parsing fixture", err, "file", file)
+		}
+	default:
+		return nil, serrors.New("unsupported fixture format", "file", file, "ext", ext)
+	}
+	var f Fixture
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, serrors.WrapStr("parsing fixture", err, "file", file)
+	}
+	return &f, nil
+}
+
+// yamlToJSON converts a YAML document to JSON, so that the JSON tags and text unmarshalers
+// of the fixture types apply to both formats.
+func yamlToJSON(raw []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	v, err := jsonValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// jsonValue converts the map[interface{}]interface{} values produced by yaml.v2 into
+// map[string]interface{}.
+func jsonValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			var err error
+			if m[fmt.Sprint(k)], err = jsonValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case []interface{}:
+		for i, e := range v {
+			var err error
+			if v[i], err = jsonValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// keys returns the hop field MAC keys of the ASes.
+func (f *Fixture) keys() map[addr.IA][]byte {
+	keys := make(map[addr.IA][]byte, len(f.ASes))
+	for ia, as := range f.ASes {
+		keys[ia] = as.Key
+	}
+	return keys
+}
+
+// paths builds the snet paths of the fixture. Timestamps and expiry times are relative to now.
+func (f *Fixture) paths(now time.Time) ([]snet.Path, error) {
+	keys := f.keys()
+	paths := make([]snet.Path, 0, len(f.Paths))
+	for i, p := range f.Paths {
+		sp, err := p.build(f, keys, now, p.lifetime())
+		if err != nil {
+			return nil, serrors.WrapStr("building path", err, "index", i,
+				"src", p.Src, "dst", p.Dst)
+		}
+		paths = append(paths, sp)
+	}
+	return paths, nil
+}
+
+// lifetime returns the configured lifetime of path p.
+func (p *Path) lifetime() time.Duration {
+	if p.Expiry.Duration == 0 {
+		return 6 * time.Hour
+	}
+	return p.Expiry.Duration
+}
+
+// build builds path p that expires at now+expiry, both in the metadata and in the hop fields.
+func (p *Path) build(f *Fixture, keys map[addr.IA][]byte, now time.Time,
+	expiry time.Duration) (snet.Path, error) {
+
+	if len(p.Hops) < 2 {
+		return nil, serrors.New("path needs at least two hops", "hops", len(p.Hops))
+	}
+	if !p.Hops[0].IA.Equal(p.Src) || !p.Hops[len(p.Hops)-1].IA.Equal(p.Dst) {
+		return nil, serrors.New("hops do not match src and dst")
+	}
+	timestamp, lifetime := hopTiming(now, expiry)
+	dp, err := NewDataplanePath(p.Hops, keys, timestamp, lifetime)
+	if err != nil {
+		return nil, err
+	}
+	nextHop := p.NextHop
+	if nextHop == "" {
+		nextHop = f.Interfaces[p.Hops[0].Egress]
+	}
+	var underlay *net.UDPAddr
+	if nextHop != "" {
+		if underlay, err = net.ResolveUDPAddr("udp", nextHop); err != nil {
+			return nil, serrors.WrapStr("parsing next hop", err, "next_hop", nextHop)
+		}
+	}
+	meta := snet.PathMetadata{
+		Interfaces:   Interfaces(p.Hops),
+		MTU:          p.MTU,
+		Expiry:       now.Add(expiry),
+		Bandwidth:    p.Bandwidth,
+		InternalHops: p.InternalHops,
+		Notes:        p.Notes,
+	}
+	for _, l := range p.Latency {
+		meta.Latency = append(meta.Latency, l.Duration)
+	}
+	for _, g := range p.Geo {
+		meta.Geo = append(meta.Geo, snet.GeoCoordinates{
+			Latitude:  g.Latitude,
+			Longitude: g.Longitude,
+			Address:   g.Address,
+		})
+	}
+	for _, lt := range p.LinkType {
+		t, err := parseLinkType(lt)
+		if err != nil {
+			return nil, err
+		}
+		meta.LinkType = append(meta.LinkType, t)
+	}
+	if p.EPIC {
+		meta.EpicAuths = snet.EpicAuths{
+			AuthPHVF: make([]byte, 16),
+			AuthLHVF: make([]byte, 16),
+		}
+	}
+	return newPath(p.Src, p.Dst, dp, underlay, meta), nil
+}
+
+func parseLinkType(s string) (snet.LinkType, error) {
+	for _, t := range []snet.LinkType{snet.LinkTypeUnset, snet.LinkTypeDirect,
+		snet.LinkTypeMultihop, snet.LinkTypeOpennet} {
+
+		if t.String() == s {
+			return t, nil
+		}
+	}
+	return snet.LinkTypeUnset, serrors.New("unknown link type", "link_type", s)
+}
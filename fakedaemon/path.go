@@ -0,0 +1,114 @@
+package fakedaemon
+
+import (
+	"hash"
+	"net"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/scrypto"
+	"github.com/scionproto/scion/pkg/slayers/path"
+	"github.com/scionproto/scion/pkg/slayers/path/scion"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+)
+
+// NewDataplanePath builds a single segment SCION path in construction direction through hops.
+// The hop field MACs are computed with the keys of the ASes; hops of ASes without a key get
+// an all zero MAC. Timestamp is the info field timestamp and expiry the lifetime of the hop
+// fields.
+func NewDataplanePath(hops []Hop, keys map[addr.IA][]byte, timestamp time.Time,
+	expiry time.Duration) (snetpath.SCION, error) {
+
+	if len(hops) < 2 || len(hops) > 64 {
+		return snetpath.SCION{}, serrors.New("invalid number of hops", "hops", len(hops))
+	}
+	expTime, err := path.ExpTimeFromDuration(expiry)
+	if err != nil {
+		return snetpath.SCION{}, serrors.WrapStr("computing expiration time", err)
+	}
+	info := path.InfoField{
+		ConsDir:   true,
+		SegID:     uint16(scrypto.RandUint64()),
+		Timestamp: uint32(timestamp.Unix()),
+	}
+	dec := scion.Decoded{
+		Base: scion.Base{
+			PathMeta: scion.MetaHdr{SegLen: [3]uint8{uint8(len(hops))}},
+			NumINF:   1,
+			NumHops:  len(hops),
+		},
+		InfoFields: []path.InfoField{info},
+		HopFields:  make([]path.HopField, len(hops)),
+	}
+	// In construction direction, the MAC of each hop is chained into the SegID used for the
+	// next hop. The info field carries the SegID of the first hop.
+	segID := info.SegID
+	for i, h := range hops {
+		hf := path.HopField{
+			ExpTime:     expTime,
+			ConsIngress: uint16(h.Ingress),
+			ConsEgress:  uint16(h.Egress),
+		}
+		if key := keys[h.IA]; len(key) != 0 {
+			mac, err := scrypto.InitMac(key)
+			if err != nil {
+				return snetpath.SCION{}, serrors.WrapStr("initializing MAC", err, "ia", h.IA)
+			}
+			hf.Mac = HopMAC(mac, segID, info.Timestamp, hf)
+		}
+		segID ^= uint16(hf.Mac[0])<<8 | uint16(hf.Mac[1])
+		dec.HopFields[i] = hf
+	}
+	raw := make([]byte, dec.Len())
+	if err := dec.SerializeTo(raw); err != nil {
+		return snetpath.SCION{}, serrors.WrapStr("serializing path", err)
+	}
+	return snetpath.SCION{Raw: raw}, nil
+}
+
+// hopTiming returns the info field timestamp and the hop field lifetime for hop fields that
+// expire at now+expiry. Hop field lifetimes are multiples of 24h/256, so the lifetime is
+// rounded up and the timestamp moved back by the difference. This also expresses expiries
+// below the minimal lifetime and negative ones, i.e. hop fields that are already expired.
+func hopTiming(now time.Time, expiry time.Duration) (time.Time, time.Duration) {
+	unit := path.MaxTTL / 256
+	lifetime := unit
+	if expiry > unit {
+		lifetime = (expiry + unit - 1) / unit * unit
+	}
+	return now.Add(expiry - lifetime), lifetime
+}
+
+// HopMAC computes the MAC of hop field hf, using the accumulated SegID of the hop.
+func HopMAC(mac hash.Hash, segID uint16, timestamp uint32, hf path.HopField) [path.MacLen]byte {
+	info := path.InfoField{ConsDir: true, SegID: segID, Timestamp: timestamp}
+	return path.MAC(mac, info, hf, nil)
+}
+
+// Interfaces returns the path interfaces of hops, as listed in snet.PathMetadata.
+func Interfaces(hops []Hop) []snet.PathInterface {
+	var ifs []snet.PathInterface
+	for _, h := range hops {
+		if h.Ingress != 0 {
+			ifs = append(ifs, snet.PathInterface{ID: h.Ingress, IA: h.IA})
+		}
+		if h.Egress != 0 {
+			ifs = append(ifs, snet.PathInterface{ID: h.Egress, IA: h.IA})
+		}
+	}
+	return ifs
+}
+
+func newPath(src, dst addr.IA, dp snet.DataplanePath, nextHop *net.UDPAddr,
+	meta snet.PathMetadata) snet.Path {
+
+	return snetpath.Path{
+		Src:           src,
+		Dst:           dst,
+		DataplanePath: dp,
+		NextHop:       nextHop,
+		Meta:          meta,
+	}
+}
@@ -0,0 +1,114 @@
+package fakedaemon
+
+import (
+	"context"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/private/util"
+	"github.com/scionproto/scion/pkg/snet"
+)
+
+// Step is a scripted behavior of the fake daemon. A call uses the first step that matches it
+// and that is not used up; calls without a matching step are answered from the fixture:
+//
+//	script:
+//	  # The first request fails, the refresh returns paths that expire in 1s.
+//	  - {method: Paths, refresh: false, times: 1, error: "no paths available"}
+//	  - {method: Paths, refresh: true, expiry: 1s}
+//	  - {method: ASInfo, delay: 2s}
+type Step struct {
+	// Method is the Connector method the step applies to, e.g. "Paths". If empty, the step
+	// applies to all methods.
+	Method string `json:"method"`
+	// Dst restricts a Paths step to requests for destination Dst.
+	Dst addr.IA `json:"dst"`
+	// Refresh restricts a Paths step to requests with the given refresh flag.
+	Refresh *bool `json:"refresh"`
+	// Times is the number of calls the step applies to. If 0, it applies to all calls.
+	Times int `json:"times"`
+
+	// Delay delays the answer. The call fails if the context is done before.
+	Delay util.DurWrap `json:"delay"`
+	// Error makes the call fail with the given message.
+	Error string `json:"error"`
+	// Empty makes a Paths call return no paths.
+	Empty bool `json:"empty"`
+	// Expiry makes a Paths call return paths that expire at the time of the call plus Expiry.
+	// The paths are rebuilt, so that both the metadata and the hop fields expire. A negative
+	// value returns paths that are already expired.
+	Expiry util.DurWrap `json:"expiry"`
+}
+
+func (s *Step) matches(method string, dst addr.IA, f daemon.PathReqFlags) bool {
+	if s.Method != "" && s.Method != method {
+		return false
+	}
+	if method != "Paths" {
+		return true
+	}
+	if !s.Dst.IsZero() && !s.Dst.Equal(dst) {
+		return false
+	}
+	return s.Refresh == nil || *s.Refresh == f.Refresh
+}
+
+// step returns the step for a call to method, or nil if no step matches. The arguments dst
+// and f are only used for Paths calls.
+func (c *Connector) step(method string, dst addr.IA, f daemon.PathReqFlags) *Step {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.fixture.Script {
+		s := &c.fixture.Script[i]
+		if !s.matches(method, dst, f) {
+			continue
+		}
+		if s.Times == 0 {
+			return s
+		}
+		if c.used[i] < s.Times {
+			c.used[i]++
+			return s
+		}
+	}
+	return nil
+}
+
+// apply runs the delay and error of step s. A nil step does nothing.
+func (s *Step) apply(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	if s.Delay.Duration > 0 {
+		t := time.NewTimer(s.Delay.Duration)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if s.Error != "" {
+		return serrors.New(s.Error)
+	}
+	return nil
+}
+
+// expiry returns the lifetime of the paths returned by a call using step s, or def if the
+// step does not change it.
+func (s *Step) expiry(def time.Duration) time.Duration {
+	if s == nil || s.Expiry.Duration == 0 {
+		return def
+	}
+	return s.Expiry.Duration
+}
+
+// paths applies the path specific behavior of step s to paths.
+func (s *Step) paths(paths []snet.Path) []snet.Path {
+	if s != nil && s.Empty {
+		return nil
+	}
+	return paths
+}
@@ -0,0 +1,193 @@
+package fakedaemon
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/ctrl/path_mgmt"
+	"github.com/scionproto/scion/pkg/private/util"
+	sdpb "github.com/scionproto/scion/pkg/proto/daemon"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+	"github.com/scionproto/scion/private/topology"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server serves a daemon.Connector over the SCION daemon gRPC API, so that unmodified clients
+// using daemon.NewService(addr).Connect can talk to it. DRKey requests are not supported.
+type Server struct {
+	sdpb.UnimplementedDaemonServiceServer
+
+	// Connector answers the requests, usually a fake Connector.
+	Connector daemon.Connector
+}
+
+// Serve accepts connections on lis until ctx is done.
+func (s *Server) Serve(ctx context.Context, lis net.Listener) error {
+	gs := grpc.NewServer()
+	sdpb.RegisterDaemonServiceServer(gs, s)
+	stop := context.AfterFunc(ctx, gs.GracefulStop)
+	defer stop()
+	if err := gs.Serve(lis); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// Paths serves the paths request.
+func (s *Server) Paths(ctx context.Context,
+	req *sdpb.PathsRequest) (*sdpb.PathsResponse, error) {
+
+	paths, err := s.Connector.Paths(ctx, addr.IA(req.DestinationIsdAs),
+		addr.IA(req.SourceIsdAs), daemon.PathReqFlags{
+			Refresh: req.Refresh,
+			Hidden:  req.Hidden,
+		})
+	if err != nil {
+		return nil, err
+	}
+	reply := &sdpb.PathsResponse{}
+	for _, p := range paths {
+		reply.Paths = append(reply.Paths, pathToPB(p))
+	}
+	return reply, nil
+}
+
+// AS serves the AS request.
+func (s *Server) AS(ctx context.Context, req *sdpb.ASRequest) (*sdpb.ASResponse, error) {
+	info, err := s.Connector.ASInfo(ctx, addr.IA(req.IsdAs))
+	if err != nil {
+		return nil, err
+	}
+	return &sdpb.ASResponse{
+		IsdAs: uint64(info.IA),
+		Mtu:   uint32(info.MTU),
+	}, nil
+}
+
+// Interfaces serves the interfaces request.
+func (s *Server) Interfaces(ctx context.Context,
+	_ *sdpb.InterfacesRequest) (*sdpb.InterfacesResponse, error) {
+
+	ifs, err := s.Connector.IFInfo(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	reply := &sdpb.InterfacesResponse{
+		Interfaces: make(map[uint64]*sdpb.Interface, len(ifs)),
+	}
+	for id, a := range ifs {
+		reply.Interfaces[uint64(id)] = &sdpb.Interface{
+			Address: &sdpb.Underlay{Address: a.String()},
+		}
+	}
+	return reply, nil
+}
+
+// Services serves the services request. Only the control service is part of the API.
+func (s *Server) Services(ctx context.Context,
+	_ *sdpb.ServicesRequest) (*sdpb.ServicesResponse, error) {
+
+	svcs, err := s.Connector.SVCInfo(ctx, []addr.SVC{addr.SvcCS})
+	if err != nil {
+		return nil, err
+	}
+	list := &sdpb.ListService{}
+	for _, uri := range svcs[addr.SvcCS] {
+		list.Services = append(list.Services, &sdpb.Service{Uri: uri})
+	}
+	return &sdpb.ServicesResponse{
+		Services: map[string]*sdpb.ListService{topology.Control.String(): list},
+	}, nil
+}
+
+// NotifyInterfaceDown passes the notification on as revocation.
+func (s *Server) NotifyInterfaceDown(ctx context.Context,
+	req *sdpb.NotifyInterfaceDownRequest) (*sdpb.NotifyInterfaceDownResponse, error) {
+
+	err := s.Connector.RevNotification(ctx, &path_mgmt.RevInfo{
+		RawIsdas:     addr.IA(req.IsdAs),
+		IfID:         common.IFIDType(req.Id),
+		RawTTL:       10,
+		RawTimestamp: util.TimeToSecs(time.Now()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sdpb.NotifyInterfaceDownResponse{}, nil
+}
+
+// pathToPB converts a path as the SCION daemon does.
+func pathToPB(path snet.Path) *sdpb.Path {
+	meta := path.Metadata()
+	interfaces := make([]*sdpb.PathInterface, len(meta.Interfaces))
+	for i, intf := range meta.Interfaces {
+		interfaces[i] = &sdpb.PathInterface{
+			Id:    uint64(intf.ID),
+			IsdAs: uint64(intf.IA),
+		}
+	}
+	latency := make([]*durationpb.Duration, len(meta.Latency))
+	for i, v := range meta.Latency {
+		latency[i] = durationpb.New(v)
+	}
+	geo := make([]*sdpb.GeoCoordinates, len(meta.Geo))
+	for i, v := range meta.Geo {
+		geo[i] = &sdpb.GeoCoordinates{
+			Latitude:  v.Latitude,
+			Longitude: v.Longitude,
+			Address:   v.Address,
+		}
+	}
+	linkType := make([]sdpb.LinkType, len(meta.LinkType))
+	for i, v := range meta.LinkType {
+		linkType[i] = linkTypeToPB(v)
+	}
+
+	var raw []byte
+	if scionPath, ok := path.Dataplane().(snetpath.SCION); ok {
+		raw = scionPath.Raw
+	}
+	nextHop := ""
+	if a := path.UnderlayNextHop(); a != nil {
+		nextHop = a.String()
+	}
+	return &sdpb.Path{
+		Raw: raw,
+		Interface: &sdpb.Interface{
+			Address: &sdpb.Underlay{Address: nextHop},
+		},
+		Interfaces:   interfaces,
+		Mtu:          uint32(meta.MTU),
+		Expiration:   timestamppb.New(meta.Expiry),
+		Latency:      latency,
+		Bandwidth:    meta.Bandwidth,
+		Geo:          geo,
+		LinkType:     linkType,
+		InternalHops: meta.InternalHops,
+		Notes:        meta.Notes,
+		EpicAuths: &sdpb.EpicAuths{
+			AuthPhvf: append([]byte(nil), meta.EpicAuths.AuthPHVF...),
+			AuthLhvf: append([]byte(nil), meta.EpicAuths.AuthLHVF...),
+		},
+	}
+}
+
+func linkTypeToPB(lt snet.LinkType) sdpb.LinkType {
+	switch lt {
+	case snet.LinkTypeDirect:
+		return sdpb.LinkType_LINK_TYPE_DIRECT
+	case snet.LinkTypeMultihop:
+		return sdpb.LinkType_LINK_TYPE_MULTI_HOP
+	case snet.LinkTypeOpennet:
+		return sdpb.LinkType_LINK_TYPE_OPEN_NET
+	default:
+		return sdpb.LinkType_LINK_TYPE_UNSPECIFIED
+	}
+}
@@ -0,0 +1,164 @@
+// Package monitor exposes the Prometheus metrics of the scion-hello commands: the packet
+// connection metrics of the connect package and the application metrics created here.
+//
+//	go monitor.Serve(ctx, "127.0.0.1:9100")
+//	srv.Metrics = monitor.NewServerMetrics(limit)
+//
+// The application metrics are labeled with the remote ISD-AS and the fingerprint of the path,
+// see echo.PathFingerprint. A LabelLimit bounds the number of these label pairs.
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/metrics"
+	"github.com/scionproto/scion/pkg/private/serrors"
+
+	"github.com/tzaeschke/scion-hello/echo"
+)
+
+// Other is the label value of the label pairs beyond a LabelLimit.
+const Other = "other"
+
+// labelKind is the label of the echo.ReplyKind of a reply.
+const labelKind = "kind"
+
+// Serve serves the metrics of the default Prometheus registry on /metrics at address until
+// ctx is done.
+func Serve(ctx context.Context, address string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return ServeHandler(ctx, address, mux)
+}
+
+// ServeHandler serves h over HTTP at address until ctx is done, e.g. a local admin endpoint.
+func ServeHandler(ctx context.Context, address string, h http.Handler) error {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return serrors.WrapStr("listening for HTTP", err, "address", address)
+	}
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	stop := context.AfterFunc(ctx, func() {
+		if err := srv.Close(); err != nil {
+			log.Error("Failed to close HTTP server", "err", err)
+		}
+	})
+	defer stop()
+	log.Info("Serving HTTP", "address", lis.Addr())
+	if err := srv.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		return serrors.WrapStr("serving HTTP", err, "address", address)
+	}
+	return ctx.Err()
+}
+
+// LabelLimit bounds the cardinality of the remote ISD-AS and path labels. The first Max label
+// pairs are used as they are, all further pairs are labeled Other. The zero value does not
+// bound the labels. A LabelLimit is safe for concurrent use.
+type LabelLimit struct {
+	Max int
+
+	mu   sync.Mutex
+	seen map[[2]string]struct{}
+}
+
+// Values returns the label values of remote ISD-AS ia and path fingerprint.
+func (l *LabelLimit) Values(ia addr.IA, fingerprint string) (string, string) {
+	remote := ia.String()
+	if l == nil || l.Max <= 0 {
+		return remote, fingerprint
+	}
+	key := [2]string{remote, fingerprint}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.seen[key]; ok {
+		return remote, fingerprint
+	}
+	if len(l.seen) >= l.Max {
+		return Other, Other
+	}
+	if l.seen == nil {
+		l.seen = make(map[[2]string]struct{})
+	}
+	l.seen[key] = struct{}{}
+	return remote, fingerprint
+}
+
+// labelNames are the names of the remote ISD-AS and path labels.
+var labelNames = []string{echo.LabelRemoteIA, echo.LabelPath}
+
+// NewServerMetrics creates and registers the metrics of an echo server.
+func NewServerMetrics(limit *LabelLimit) echo.Metrics {
+	return echo.Metrics{
+		Handled: metrics.NewPromCounterFrom(prometheus.CounterOpts{
+			Name: "hello_server_pings_handled_total",
+			Help: "Total number of admitted requests.",
+		}, labelNames),
+		Replies: metrics.NewPromCounterFrom(prometheus.CounterOpts{
+			Name: "hello_server_replies_sent_total",
+			Help: "Total number of replies sent.",
+		}, labelNames),
+		DecodeFailures: metrics.NewPromCounterFrom(prometheus.CounterOpts{
+			Name: "hello_server_decode_failures_total",
+			Help: "Total number of requests with an undecodable payload.",
+		}, nil),
+		Oversized: metrics.NewPromCounterFrom(prometheus.CounterOpts{
+			Name: "hello_server_oversized_replies_total",
+			Help: "Total number of requests dropped because the reply payload was larger.",
+		}, nil),
+		ReversalFailures: metrics.NewPromCounterFrom(prometheus.CounterOpts{
+			Name: "hello_server_path_reversal_failures_total",
+			Help: "Total number of requests whose path could not be reversed.",
+		}, labelNames[:1]),
+		Labels: limit.Values,
+	}
+}
+
+// Client are the metrics of a client.
+type Client struct {
+	// Replies counts the replies by remote ISD-AS, path and echo.ReplyKind.
+	Replies metrics.Counter
+	// RTT observes the round trip times in seconds by remote ISD-AS and path.
+	RTT metrics.Histogram
+
+	limit *LabelLimit
+}
+
+// NewClientMetrics creates and registers the metrics of a client.
+func NewClientMetrics(limit *LabelLimit) *Client {
+	return &Client{
+		Replies: metrics.NewPromCounterFrom(prometheus.CounterOpts{
+			Name: "hello_client_replies_total",
+			Help: "Total number of replies received, by kind.",
+		}, append(labelNames[:2:2], labelKind)),
+		RTT: metrics.NewPromHistogramFrom(prometheus.HistogramOpts{
+			Name:    "hello_client_rtt_seconds",
+			Help:    "Round trip time of the answered requests.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 16),
+		}, labelNames),
+		limit: limit,
+	}
+}
+
+// Observe records reply r from remote ISD-AS ia over the path with fingerprint. It does
+// nothing if c is nil.
+func (c *Client) Observe(ia addr.IA, fingerprint string, r echo.Reply) {
+	if c == nil {
+		return
+	}
+	remote, path := c.limit.Values(ia, fingerprint)
+	labels := []string{echo.LabelRemoteIA, remote, echo.LabelPath, path}
+	kind := append(labels[:4:4], labelKind, r.Kind.String())
+	metrics.CounterInc(metrics.CounterWith(c.Replies, kind...))
+	if r.Kind == echo.InOrder || r.Kind == echo.Reordered {
+		metrics.HistogramObserve(metrics.HistogramWith(c.RTT, labels...), r.RTT.Seconds())
+	}
+}
@@ -0,0 +1,164 @@
+package monitor_test
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/metrics"
+
+	"github.com/tzaeschke/scion-hello/echo"
+	"github.com/tzaeschke/scion-hello/monitor"
+)
+
+var (
+	ia111 = mustParseIA("1-ff00:0:111")
+	ia112 = mustParseIA("1-ff00:0:112")
+)
+
+func mustParseIA(s string) addr.IA {
+	ia, err := addr.ParseIA(s)
+	if err != nil {
+		panic(err)
+	}
+	return ia
+}
+
+func TestLabelLimitValues(t *testing.T) {
+	type pair struct {
+		ia          addr.IA
+		fingerprint string
+	}
+	tests := map[string]struct {
+		limit *monitor.LabelLimit
+		pairs []pair
+		// want are the label values of pairs, in order.
+		want [][2]string
+	}{
+		"nil": {
+			pairs: []pair{{ia111, "a"}, {ia112, "b"}},
+			want:  [][2]string{{"1-ff00:0:111", "a"}, {"1-ff00:0:112", "b"}},
+		},
+		"unbounded": {
+			limit: &monitor.LabelLimit{},
+			pairs: []pair{{ia111, "a"}, {ia112, "b"}},
+			want:  [][2]string{{"1-ff00:0:111", "a"}, {"1-ff00:0:112", "b"}},
+		},
+		"beyond Max": {
+			limit: &monitor.LabelLimit{Max: 2},
+			pairs: []pair{{ia111, "a"}, {ia111, "b"}, {ia112, "a"}, {ia112, "c"}},
+			want: [][2]string{
+				{"1-ff00:0:111", "a"}, {"1-ff00:0:111", "b"},
+				{monitor.Other, monitor.Other}, {monitor.Other, monitor.Other},
+			},
+		},
+		"seen pairs keep their labels": {
+			limit: &monitor.LabelLimit{Max: 1},
+			pairs: []pair{{ia111, "a"}, {ia112, "b"}, {ia111, "a"}, {ia111, "b"}},
+			want: [][2]string{
+				{"1-ff00:0:111", "a"}, {monitor.Other, monitor.Other},
+				{"1-ff00:0:111", "a"}, {monitor.Other, monitor.Other},
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got [][2]string
+			for _, p := range tc.pairs {
+				remote, path := tc.limit.Values(p.ia, p.fingerprint)
+				got = append(got, [2]string{remote, path})
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Values() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLabelLimitConcurrently(t *testing.T) {
+	limit := &monitor.LabelLimit{Max: 10}
+	var wg sync.WaitGroup
+	others := make(chan int, 4)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n := 0
+			for i := 0; i < 100; i++ {
+				fingerprint := string(rune('a' + i%26))
+				if remote, _ := limit.Values(ia111, fingerprint); remote == monitor.Other {
+					n++
+				}
+			}
+			others <- n
+		}()
+	}
+	wg.Wait()
+	close(others)
+	// 10 of the 26 fingerprints are labeled, each goroutine sees the other 16 three or four
+	// times.
+	for n := range others {
+		if n < 16*3 || n > 16*4 {
+			t.Errorf("%d pairs labeled %s, want those of 16 fingerprints", n, monitor.Other)
+		}
+	}
+}
+
+// TestMetrics tests the server and client metrics. They register with the default registerer,
+// which is replaced by a fresh registry for the test.
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	defer func(r prometheus.Registerer) { prometheus.DefaultRegisterer = r }(
+		prometheus.DefaultRegisterer)
+	prometheus.DefaultRegisterer = registry
+
+	limit := &monitor.LabelLimit{Max: 1}
+	srv := monitor.NewServerMetrics(limit)
+	for _, ia := range []addr.IA{ia111, ia112, ia111} {
+		remote, path := srv.Labels(ia, "fp")
+		metrics.CounterInc(srv.Handled.With(echo.LabelRemoteIA, remote, echo.LabelPath, path))
+	}
+	client := monitor.NewClientMetrics(limit)
+	client.Observe(ia111, "fp", echo.Reply{Kind: echo.InOrder, RTT: time.Millisecond})
+	client.Observe(ia112, "fp", echo.Reply{Kind: echo.Duplicate})
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]string)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			var labels []string
+			for _, l := range m.GetLabel() {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			sort.Strings(labels)
+			got[f.GetName()] = append(got[f.GetName()], strings.Join(labels, ","))
+		}
+	}
+	want := map[string][]string{
+		"hello_server_pings_handled_total": {
+			"path=fp,remote_isd_as=1-ff00:0:111",
+			"path=other,remote_isd_as=other",
+		},
+		"hello_client_replies_total": {
+			"kind=duplicate,path=other,remote_isd_as=other",
+			"kind=in order,path=fp,remote_isd_as=1-ff00:0:111",
+		},
+		"hello_client_rtt_seconds": {
+			"path=fp,remote_isd_as=1-ff00:0:111",
+		},
+	}
+	for name, series := range want {
+		sort.Strings(got[name])
+		if !reflect.DeepEqual(got[name], series) {
+			t.Errorf("%s series = %q, want %q", name, got[name], series)
+		}
+	}
+}
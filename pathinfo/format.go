@@ -0,0 +1,156 @@
+package pathinfo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"gopkg.in/yaml.v2"
+)
+
+// Format is an output format of a List.
+type Format int
+
+const (
+	// JSON writes the list as indented JSON document.
+	JSON Format = iota
+	// YAML writes the list as YAML document.
+	YAML
+	// CSV writes one row per hop, with the columns in CSVHeader. The columns of the path are
+	// repeated in every row of its hops.
+	CSV
+)
+
+// formats are the names of the formats, in the order of their values.
+var formats = []string{"json", "yaml", "csv"}
+
+// Formats returns the names of the formats.
+func Formats() []string {
+	return append([]string(nil), formats...)
+}
+
+func (f Format) String() string {
+	if f < 0 || int(f) >= len(formats) {
+		return "unknown"
+	}
+	return formats[f]
+}
+
+// Set parses the name of a format, so that a Format can be used as flag.
+func (f *Format) Set(name string) error {
+	for i, n := range formats {
+		if strings.EqualFold(name, n) {
+			*f = Format(i)
+			return nil
+		}
+	}
+	return serrors.New("unknown format", "format", name, "known", strings.Join(formats, ", "))
+}
+
+// CSVHeader are the columns of the CSV format. path is the index of the path in the list,
+// hop the index of the hop on the path.
+var CSVHeader = []string{
+	"src", "dst", "path", "fingerprint", "mtu", "expiry", "epic_auth",
+	"hop", "isd_as", "ingress", "egress",
+	"ingress_latitude", "ingress_longitude", "ingress_address",
+	"egress_latitude", "egress_longitude", "egress_address",
+	"internal_hops", "internal_latency_ms", "internal_bandwidth_kbps", "notes",
+	"link_type", "link_latency_ms", "link_bandwidth_kbps",
+}
+
+// Write writes the list to w in format f.
+func (l List) Write(w io.Writer, f Format) error {
+	switch f {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(l)
+	case YAML:
+		raw, err := yaml.Marshal(l)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(raw)
+		return err
+	case CSV:
+		return l.writeCSV(w)
+	default:
+		return serrors.New("unknown format", "format", f)
+	}
+}
+
+func (l List) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+	for i, p := range l.Paths {
+		for j, h := range p.Hops {
+			row := []string{
+				l.Source, l.Destination, strconv.Itoa(i), p.Fingerprint,
+				strconv.Itoa(int(p.MTU)), p.Expiry, strconv.FormatBool(p.EPICAuth),
+				strconv.Itoa(j), h.IA,
+				strconv.FormatUint(h.Ingress, 10), strconv.FormatUint(h.Egress, 10),
+			}
+			row = append(row, geoColumns(h.IngressGeo)...)
+			row = append(row, geoColumns(h.EgressGeo)...)
+			row = append(row,
+				formatHops(h.InternalHops), formatFloat(h.InternalLatencyMS),
+				formatUint(h.InternalBandwidthKbps), h.Notes)
+			if h.Link != nil {
+				linkType := ""
+				if h.Link.Type != nil {
+					linkType = *h.Link.Type
+				}
+				row = append(row, linkType, formatFloat(h.Link.LatencyMS),
+					formatUint(h.Link.BandwidthKbps))
+			} else {
+				row = append(row, "", "", "")
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// geoColumns returns the CSV columns of a location, empty if it is unknown.
+func geoColumns(g *Geo) []string {
+	if g == nil {
+		return []string{"", "", ""}
+	}
+	return []string{
+		strconv.FormatFloat(float64(g.Latitude), 'f', -1, 32),
+		strconv.FormatFloat(float64(g.Longitude), 'f', -1, 32),
+		g.Address,
+	}
+}
+
+// formatHops formats an optional number of hops, empty if it is unknown.
+func formatHops(v *uint32) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*v), 10)
+}
+
+// formatUint formats an optional unsigned integer, empty if it is unknown.
+func formatUint(v *uint64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatUint(*v, 10)
+}
+
+// formatFloat formats an optional float, empty if it is unknown.
+func formatFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
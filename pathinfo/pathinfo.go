@@ -0,0 +1,186 @@
+// Package pathinfo describes SCION paths in a stable, machine-readable schema, e.g. for
+// dashboards and notebooks. A List holds the paths between two ASes and is written as JSON,
+// YAML or CSV:
+//
+//	list := pathinfo.NewList(src, dst, paths)
+//	err := list.Write(os.Stdout, pathinfo.JSON)
+//
+// The path metadata announced by the ASes is often incomplete. Unknown values are null in
+// JSON and YAML, and empty in CSV. The schema only grows; fields are not renamed or removed
+// without increasing Version.
+package pathinfo
+
+import (
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+)
+
+// Version is the version of the schema.
+const Version = 1
+
+// List are the paths between two ASes.
+type List struct {
+	Version     int    `json:"version" yaml:"version"`
+	Source      string `json:"src" yaml:"src"`
+	Destination string `json:"dst" yaml:"dst"`
+	Paths       []Path `json:"paths" yaml:"paths"`
+}
+
+// Path is a path and its metadata.
+type Path struct {
+	// Fingerprint identifies the path by its interfaces, see snet.Fingerprint. It is empty for
+	// the empty path within an AS.
+	Fingerprint string `json:"fingerprint" yaml:"fingerprint"`
+	// Hops are the ASes of the path, from the source to the destination.
+	Hops []Hop `json:"hops" yaml:"hops"`
+	// MTU is the maximum transmission unit of the path in bytes.
+	MTU uint16 `json:"mtu" yaml:"mtu"`
+	// Expiry is the expiration time of the path in RFC 3339 format.
+	Expiry string `json:"expiry" yaml:"expiry"`
+	// EPICAuth tells whether the authenticators for EPIC are available.
+	EPICAuth bool `json:"epic_auth" yaml:"epic_auth"`
+}
+
+// Hop is an AS on a path.
+type Hop struct {
+	IA string `json:"isd_as" yaml:"isd_as"`
+	// Ingress is the interface where the path enters the AS, 0 for the source.
+	Ingress uint64 `json:"ingress" yaml:"ingress"`
+	// Egress is the interface where the path leaves the AS, 0 for the destination.
+	Egress uint64 `json:"egress" yaml:"egress"`
+	// IngressGeo and EgressGeo are the locations of the interfaces.
+	IngressGeo *Geo `json:"ingress_geo" yaml:"ingress_geo"`
+	EgressGeo  *Geo `json:"egress_geo" yaml:"egress_geo"`
+	// InternalHops is the number of router hops from Ingress to Egress.
+	InternalHops *uint32 `json:"internal_hops" yaml:"internal_hops"`
+	// InternalLatencyMS is the latency from Ingress to Egress in milliseconds.
+	InternalLatencyMS *float64 `json:"internal_latency_ms" yaml:"internal_latency_ms"`
+	// InternalBandwidthKbps is the bandwidth from Ingress to Egress in Kbit/s.
+	InternalBandwidthKbps *uint64 `json:"internal_bandwidth_kbps" yaml:"internal_bandwidth_kbps"`
+	// Notes is the free-form note announced by the AS.
+	Notes string `json:"notes" yaml:"notes"`
+	// Link is the link from Egress to the next hop, nil for the destination.
+	Link *Link `json:"link" yaml:"link"`
+}
+
+// Geo is the location of an interface.
+type Geo struct {
+	Latitude  float32 `json:"latitude" yaml:"latitude"`
+	Longitude float32 `json:"longitude" yaml:"longitude"`
+	Address   string  `json:"address" yaml:"address"`
+}
+
+// Link is an inter-AS link.
+type Link struct {
+	// Type is the type of the link, e.g. "direct" or "opennet", nil if unknown.
+	Type *string `json:"type" yaml:"type"`
+	// LatencyMS is the latency of the link in milliseconds.
+	LatencyMS *float64 `json:"latency_ms" yaml:"latency_ms"`
+	// BandwidthKbps is the bandwidth of the link in Kbit/s.
+	BandwidthKbps *uint64 `json:"bandwidth_kbps" yaml:"bandwidth_kbps"`
+}
+
+// NewList describes paths from src to dst, in the order of paths.
+func NewList(src, dst addr.IA, paths []snet.Path) List {
+	l := List{
+		Version:     Version,
+		Source:      src.String(),
+		Destination: dst.String(),
+		Paths:       make([]Path, 0, len(paths)),
+	}
+	for _, p := range paths {
+		l.Paths = append(l.Paths, NewPath(p))
+	}
+	return l
+}
+
+// NewPath describes path p.
+func NewPath(p snet.Path) Path {
+	meta := p.Metadata()
+	if meta == nil {
+		meta = &snet.PathMetadata{}
+	}
+	desc := Path{
+		Fingerprint: snet.Fingerprint(p).String(),
+		MTU:         meta.MTU,
+		EPICAuth:    meta.EpicAuths.SupportsEpic(),
+	}
+	if !meta.Expiry.IsZero() {
+		desc.Expiry = meta.Expiry.UTC().Format(time.RFC3339)
+	}
+
+	intfs := meta.Interfaces
+	if len(intfs) == 0 {
+		// The empty path within an AS.
+		desc.Hops = []Hop{{IA: p.Source().String()}}
+		return desc
+	}
+	// The interfaces come in pairs, one per link: the egress of an AS and the ingress of the
+	// next one. Latency and Bandwidth are given between consecutive interfaces, i.e. they
+	// alternate between links and AS internal connections.
+	n := len(intfs)/2 + 1
+	desc.Hops = make([]Hop, n)
+	for k := range desc.Hops {
+		hop := &desc.Hops[k]
+		if k > 0 {
+			in := 2*k - 1
+			hop.IA = intfs[in].IA.String()
+			hop.Ingress = uint64(intfs[in].ID)
+			hop.IngressGeo = geo(meta.Geo, in)
+		}
+		if k < n-1 {
+			out := 2 * k
+			hop.IA = intfs[out].IA.String()
+			hop.Egress = uint64(intfs[out].ID)
+			hop.EgressGeo = geo(meta.Geo, out)
+			hop.Link = &Link{
+				LatencyMS:     latency(meta.Latency, out),
+				BandwidthKbps: bandwidth(meta.Bandwidth, out),
+			}
+			if k < len(meta.LinkType) {
+				t := meta.LinkType[k].String()
+				hop.Link.Type = &t
+			}
+		}
+		if k > 0 && k < n-1 {
+			hop.InternalLatencyMS = latency(meta.Latency, 2*k-1)
+			hop.InternalBandwidthKbps = bandwidth(meta.Bandwidth, 2*k-1)
+			if k-1 < len(meta.InternalHops) {
+				h := meta.InternalHops[k-1]
+				hop.InternalHops = &h
+			}
+		}
+		if k < len(meta.Notes) {
+			hop.Notes = meta.Notes[k]
+		}
+	}
+	return desc
+}
+
+// latency returns the i-th latency in milliseconds, nil if it is unknown.
+func latency(l []time.Duration, i int) *float64 {
+	if i >= len(l) || l[i] < 0 {
+		return nil
+	}
+	ms := float64(l[i]) / float64(time.Millisecond)
+	return &ms
+}
+
+// bandwidth returns the i-th bandwidth, nil if it is unknown.
+func bandwidth(bw []uint64, i int) *uint64 {
+	if i >= len(bw) || bw[i] == 0 {
+		return nil
+	}
+	b := bw[i]
+	return &b
+}
+
+// geo returns the i-th location, nil if it is unknown.
+func geo(g []snet.GeoCoordinates, i int) *Geo {
+	if i >= len(g) || g[i] == (snet.GeoCoordinates{}) {
+		return nil
+	}
+	return &Geo{Latitude: g[i].Latitude, Longitude: g[i].Longitude, Address: g[i].Address}
+}
@@ -0,0 +1,166 @@
+package pathinfo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+	"gopkg.in/yaml.v2"
+
+	"github.com/tzaeschke/scion-hello/pathinfo"
+)
+
+// update rewrites the golden files with the current output.
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func mustParseIA(s string) addr.IA {
+	ia, err := addr.ParseIA(s)
+	if err != nil {
+		panic(err)
+	}
+	return ia
+}
+
+var (
+	ia110 = mustParseIA("1-ff00:0:110")
+	ia111 = mustParseIA("1-ff00:0:111")
+	ia112 = mustParseIA("1-ff00:0:112")
+)
+
+// testList returns a list of a path with complete metadata, a path with little metadata and
+// the empty path.
+func testList() pathinfo.List {
+	nextHop := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 17), Port: 31002}
+	full := snetpath.Path{
+		Src:     ia111,
+		Dst:     ia112,
+		NextHop: nextHop,
+		Meta: snet.PathMetadata{
+			Interfaces: []snet.PathInterface{
+				{IA: ia111, ID: 41}, {IA: ia110, ID: 1}, {IA: ia110, ID: 2}, {IA: ia112, ID: 1},
+			},
+			MTU:    1472,
+			Expiry: time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC),
+			Latency: []time.Duration{
+				1500 * time.Microsecond, 200 * time.Microsecond, 3 * time.Millisecond,
+			},
+			Bandwidth: []uint64{100000, 2000000, 50000},
+			Geo: []snet.GeoCoordinates{
+				{Latitude: 47.376, Longitude: 8.548, Address: "Zurich"},
+				{Latitude: 46.948, Longitude: 7.447, Address: "Bern"},
+				{Latitude: 46.948, Longitude: 7.447, Address: "Bern"},
+				{Latitude: 46.204, Longitude: 6.143, Address: "Geneva"},
+			},
+			LinkType:     []snet.LinkType{snet.LinkTypeDirect, snet.LinkTypeOpennet},
+			InternalHops: []uint32{2},
+			Notes:        []string{"source", "core", "destination, \"quoted\""},
+			EpicAuths: snet.EpicAuths{
+				AuthPHVF: make([]byte, 16),
+				AuthLHVF: make([]byte, 16),
+			},
+		},
+	}
+	sparse := snetpath.Path{
+		Src:     ia111,
+		Dst:     ia112,
+		NextHop: nextHop,
+		Meta: snet.PathMetadata{
+			Interfaces: []snet.PathInterface{{IA: ia111, ID: 42}, {IA: ia112, ID: 2}},
+			Latency:    []time.Duration{snet.LatencyUnset},
+		},
+	}
+	empty := snetpath.Path{Src: ia111, Dst: ia111}
+	return pathinfo.NewList(ia111, ia112, []snet.Path{full, sparse, empty})
+}
+
+func TestWriteGolden(t *testing.T) {
+	tests := map[pathinfo.Format]string{
+		pathinfo.JSON: "paths.json",
+		pathinfo.YAML: "paths.yaml",
+		pathinfo.CSV:  "paths.csv",
+	}
+	for f, file := range tests {
+		t.Run(f.String(), func(t *testing.T) {
+			var got bytes.Buffer
+			if err := testList().Write(&got, f); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", file)
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("output differs from %s, run with -update if the change is intended"+
+					":\n%s", golden, got.String())
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := map[pathinfo.Format]func([]byte, interface{}) error{
+		pathinfo.JSON: json.Unmarshal,
+		pathinfo.YAML: yaml.Unmarshal,
+	}
+	want := testList()
+	for f, unmarshal := range tests {
+		t.Run(f.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := want.Write(&buf, f); err != nil {
+				t.Fatal(err)
+			}
+			var got pathinfo.List
+			if err := unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("decoded %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestFormatSet(t *testing.T) {
+	tests := map[string]struct {
+		want pathinfo.Format
+		err  bool
+	}{
+		"json": {want: pathinfo.JSON},
+		"YAML": {want: pathinfo.YAML},
+		"csv":  {want: pathinfo.CSV},
+		"xml":  {err: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var f pathinfo.Format
+			err := f.Set(name)
+			if tc.err {
+				if err == nil {
+					t.Errorf("Set(%q) = %v, want error", name, f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f != tc.want {
+				t.Errorf("Set(%q) = %v, want %v", name, f, tc.want)
+			}
+		})
+	}
+}
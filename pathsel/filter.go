@@ -0,0 +1,83 @@
+package pathsel
+
+import (
+	"strings"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+)
+
+// ErrExcluded is returned by Filter.Check and Selector.Evaluate for excluded paths.
+var ErrExcluded = serrors.New("path excluded")
+
+// Filter excludes the paths through certain ISDs, ASes or links. The source and destination
+// AS count as well. Paths without metadata are not excluded. The zero value excludes no path.
+type Filter struct {
+	// ISDs are the excluded ISDs.
+	ISDs []addr.ISD
+	// ASes are the excluded ASes, in any ISD.
+	ASes []addr.AS
+	// LinkTypes are the excluded types of inter-AS links.
+	LinkTypes []snet.LinkType
+}
+
+// Apply returns the paths that are not excluded. It does not modify paths.
+func (f Filter) Apply(paths []snet.Path) []snet.Path {
+	allowed := make([]snet.Path, 0, len(paths))
+	for _, p := range paths {
+		if f.Check(p) == nil {
+			allowed = append(allowed, p)
+		}
+	}
+	return allowed
+}
+
+// Check returns an ErrExcluded error telling why path p is excluded, nil if it is not.
+func (f Filter) Check(p snet.Path) error {
+	meta := p.Metadata()
+	if meta == nil {
+		return nil
+	}
+	for _, intf := range meta.Interfaces {
+		for _, isd := range f.ISDs {
+			if intf.IA.ISD() == isd {
+				return serrors.WithCtx(ErrExcluded, "isd", isd, "interface", intf)
+			}
+		}
+		for _, as := range f.ASes {
+			if intf.IA.AS() == as {
+				return serrors.WithCtx(ErrExcluded, "as", as, "interface", intf)
+			}
+		}
+	}
+	for i, lt := range meta.LinkType {
+		for _, excluded := range f.LinkTypes {
+			if lt == excluded {
+				return serrors.WithCtx(ErrExcluded, "link_type", lt, "link", i)
+			}
+		}
+	}
+	return nil
+}
+
+// linkTypes are the link types by name.
+var linkTypes = []snet.LinkType{
+	snet.LinkTypeUnset,
+	snet.LinkTypeDirect,
+	snet.LinkTypeMultihop,
+	snet.LinkTypeOpennet,
+}
+
+// ParseLinkType parses the name of a link type, e.g. "opennet".
+func ParseLinkType(s string) (snet.LinkType, error) {
+	names := make([]string, 0, len(linkTypes))
+	for _, lt := range linkTypes {
+		if strings.EqualFold(s, lt.String()) {
+			return lt, nil
+		}
+		names = append(names, lt.String())
+	}
+	return 0, serrors.New("unknown link type", "link_type", s,
+		"known", strings.Join(names, ", "))
+}
@@ -0,0 +1,129 @@
+package pathsel_test
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+
+	"github.com/tzaeschke/scion-hello/pathsel"
+)
+
+func TestFilterCheck(t *testing.T) {
+	ia111, ia110, ia210 := mustParseIA("1-ff00:0:111"), mustParseIA("1-ff00:0:110"),
+		mustParseIA("2-ff00:0:210")
+	// A path from 111 over the core AS 110 to 210, a direct link followed by an opennet link.
+	p := testPath(1, snet.PathMetadata{
+		Interfaces: []snet.PathInterface{
+			{IA: ia111, ID: 41}, {IA: ia110, ID: 1}, {IA: ia110, ID: 3}, {IA: ia210, ID: 1},
+		},
+		LinkType: []snet.LinkType{snet.LinkTypeDirect, snet.LinkTypeOpennet},
+	})
+	as := func(s string) addr.AS {
+		as, err := addr.ParseAS(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return as
+	}
+
+	tests := map[string]struct {
+		filter   pathsel.Filter
+		path     snet.Path
+		excluded bool
+	}{
+		"zero filter": {path: p},
+		"source ISD": {
+			filter:   pathsel.Filter{ISDs: []addr.ISD{1}},
+			path:     p,
+			excluded: true,
+		},
+		"destination ISD": {
+			filter:   pathsel.Filter{ISDs: []addr.ISD{3, 2}},
+			path:     p,
+			excluded: true,
+		},
+		"other ISD": {filter: pathsel.Filter{ISDs: []addr.ISD{3}}, path: p},
+		"transit AS": {
+			filter:   pathsel.Filter{ASes: []addr.AS{as("ff00:0:110")}},
+			path:     p,
+			excluded: true,
+		},
+		"source AS": {
+			filter:   pathsel.Filter{ASes: []addr.AS{as("ff00:0:111")}},
+			path:     p,
+			excluded: true,
+		},
+		"other AS": {filter: pathsel.Filter{ASes: []addr.AS{as("ff00:0:112")}}, path: p},
+		"opennet link": {
+			filter:   pathsel.Filter{LinkTypes: []snet.LinkType{snet.LinkTypeOpennet}},
+			path:     p,
+			excluded: true,
+		},
+		"multihop link": {
+			filter: pathsel.Filter{LinkTypes: []snet.LinkType{snet.LinkTypeMultihop}},
+			path:   p,
+		},
+		"no metadata": {
+			filter: pathsel.Filter{
+				ISDs:      []addr.ISD{1},
+				ASes:      []addr.AS{as("ff00:0:110")},
+				LinkTypes: []snet.LinkType{snet.LinkTypeOpennet},
+			},
+			path: noMetadata{snetpath.Path{NextHop: &net.UDPAddr{Port: 2}}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tc.filter.Check(tc.path)
+			if tc.excluded != (err != nil) {
+				t.Fatalf("Check() = %v, want excluded %v", err, tc.excluded)
+			}
+			if err != nil && !errors.Is(err, pathsel.ErrExcluded) {
+				t.Errorf("Check() = %v, want %v", err, pathsel.ErrExcluded)
+			}
+			want := []snet.Path{}
+			if !tc.excluded {
+				want = []snet.Path{tc.path}
+			}
+			if got := tc.filter.Apply([]snet.Path{tc.path}); !reflect.DeepEqual(got, want) {
+				t.Errorf("Apply() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseLinkType(t *testing.T) {
+	tests := map[string]struct {
+		want snet.LinkType
+		err  bool
+	}{
+		"direct":   {want: snet.LinkTypeDirect},
+		"multihop": {want: snet.LinkTypeMultihop},
+		"opennet":  {want: snet.LinkTypeOpennet},
+		"OpenNet":  {want: snet.LinkTypeOpennet},
+		"unset":    {want: snet.LinkTypeUnset},
+		"fiber":    {err: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			lt, err := pathsel.ParseLinkType(name)
+			if tc.err {
+				if err == nil {
+					t.Errorf("ParseLinkType(%q) = %v, want error", name, lt)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lt != tc.want {
+				t.Errorf("ParseLinkType(%q) = %v, want %v", name, lt, tc.want)
+			}
+		})
+	}
+}
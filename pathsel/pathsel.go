@@ -0,0 +1,221 @@
+// Package pathsel selects SCION paths by the metadata announced for them. A Selector drops
+// the paths excluded by a Filter or a path policy and ranks the others by a Strategy:
+//
+//	sel := pathsel.Selector{
+//		Strategy: pathsel.Latency,
+//		Filter:   pathsel.Filter{ASes: []addr.AS{transit}},
+//	}
+//	paths = sel.Select(paths)
+//	best := paths[0]
+//
+// Metadata is often incomplete. Unknown entries are ignored, and paths without any known
+// entry of the ranked metadata come last. Paths that rank equally keep their order, i.e. the
+// order of the daemon.
+package pathsel
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+	"github.com/scionproto/scion/private/path/pathpol"
+)
+
+// Strategy is a ranking of paths.
+type Strategy int
+
+const (
+	// Shortest prefers the fewest AS hops, i.e. inter-AS links.
+	Shortest Strategy = iota
+	// Latency prefers the lowest sum of the announced latencies.
+	Latency
+	// Bandwidth prefers the highest bottleneck bandwidth, i.e. the highest minimum of the
+	// announced bandwidths.
+	Bandwidth
+	// MTU prefers the largest MTU.
+	MTU
+	// Hops prefers the fewest router hops, i.e. the inter-AS links plus the announced AS
+	// internal hops.
+	Hops
+)
+
+// strategies are the names of the strategies, in the order of their values.
+var strategies = []string{"shortest", "latency", "bandwidth", "mtu", "hops"}
+
+// Strategies returns the names of the strategies.
+func Strategies() []string {
+	return append([]string(nil), strategies...)
+}
+
+func (s Strategy) String() string {
+	if s < 0 || int(s) >= len(strategies) {
+		return "unknown"
+	}
+	return strategies[s]
+}
+
+// Set parses the name of a strategy, so that a Strategy can be used as flag.
+func (s *Strategy) Set(name string) error {
+	for i, n := range strategies {
+		if strings.EqualFold(name, n) {
+			*s = Strategy(i)
+			return nil
+		}
+	}
+	return serrors.New("unknown path selection strategy", "strategy", name,
+		"known", strings.Join(strategies, ", "))
+}
+
+// Selector selects paths. The zero value ranks all paths by Shortest.
+type Selector struct {
+	Strategy Strategy
+	Filter   Filter
+	// Policy is a path policy in the SCION path policy language, see LoadPolicy. Its options
+	// choose among the paths allowed by Filter. A policy excludes the paths without
+	// metadata. If nil, it allows all paths.
+	Policy *pathpol.Policy
+}
+
+// Decision tells whether a path was selected.
+type Decision struct {
+	Path snet.Path
+	// Excluded tells why the path was excluded, see ErrExcluded. It is nil for selected paths.
+	Excluded error
+}
+
+// Select returns the paths allowed by the filter and the policy, the best first. It does not
+// modify paths.
+func (s Selector) Select(paths []snet.Path) []snet.Path {
+	selected, _ := s.Evaluate(paths)
+	return selected
+}
+
+// Evaluate is like Select, but also returns the decision for every path, in the order of
+// paths.
+func (s Selector) Evaluate(paths []snet.Path) ([]snet.Path, []Decision) {
+	decisions := make([]Decision, 0, len(paths))
+	filtered := make([]snet.Path, 0, len(paths))
+	for _, p := range paths {
+		err := s.Filter.Check(p)
+		decisions = append(decisions, Decision{Path: p, Excluded: err})
+		if err == nil {
+			filtered = append(filtered, p)
+		}
+	}
+	if s.Policy != nil {
+		// The options of the policy choose among the paths the filter leaves.
+		allowed := allowedPaths(s.Policy, filtered)
+		for i, d := range decisions {
+			if d.Excluded == nil {
+				decisions[i].Excluded = checkPolicy(s.Policy, d.Path, allowed)
+			}
+		}
+	}
+	selected := make([]snet.Path, 0, len(paths))
+	for _, d := range decisions {
+		if d.Excluded == nil {
+			selected = append(selected, d.Path)
+		}
+	}
+	Sort(selected, s.Strategy)
+	return selected, decisions
+}
+
+// Sort sorts paths by strategy s, the best path first.
+func Sort(paths []snet.Path, s Strategy) {
+	keys := make([]int64, len(paths))
+	for i, p := range paths {
+		keys[i] = s.key(p.Metadata())
+	}
+	sort.Stable(byKey{paths: paths, keys: keys})
+}
+
+// Best returns the best path by strategy s, nil if there is none. It does not modify paths.
+func Best(paths []snet.Path, s Strategy) snet.Path {
+	var best snet.Path
+	var bestKey int64
+	for _, p := range paths {
+		if k := s.key(p.Metadata()); best == nil || k < bestKey {
+			best, bestKey = p, k
+		}
+	}
+	return best
+}
+
+// unknown is the key of paths without the ranked metadata.
+const unknown = int64(^uint64(0) >> 1)
+
+// key returns the rank of a path with metadata meta, lower is better.
+func (s Strategy) key(meta *snet.PathMetadata) int64 {
+	if meta == nil {
+		return unknown
+	}
+	switch s {
+	case Shortest:
+		// A path without interfaces is not known to be short. Only the empty path within the
+		// local AS has none, and it is the only path there.
+		if len(meta.Interfaces) > 0 {
+			return int64(len(meta.Interfaces))
+		}
+	case Latency:
+		if l, ok := TotalLatency(meta); ok {
+			return int64(l)
+		}
+	case Bandwidth:
+		if bw, ok := BottleneckBandwidth(meta); ok {
+			return -int64(bw)
+		}
+	case MTU:
+		if meta.MTU != 0 {
+			return -int64(meta.MTU)
+		}
+	case Hops:
+		if len(meta.Interfaces) > 0 {
+			hops := int64(len(meta.Interfaces) / 2)
+			for _, h := range meta.InternalHops {
+				hops += int64(h)
+			}
+			return hops
+		}
+	}
+	return unknown
+}
+
+// TotalLatency returns the sum of the announced latencies of a path. ok is false if no
+// latency is known.
+func TotalLatency(meta *snet.PathMetadata) (total time.Duration, ok bool) {
+	for _, l := range meta.Latency {
+		if l >= 0 {
+			total += l
+			ok = true
+		}
+	}
+	return total, ok
+}
+
+// BottleneckBandwidth returns the minimum of the announced bandwidths of a path in Kbit/s.
+// ok is false if no bandwidth is known.
+func BottleneckBandwidth(meta *snet.PathMetadata) (bw uint64, ok bool) {
+	for _, b := range meta.Bandwidth {
+		if b != 0 && (!ok || b < bw) {
+			bw, ok = b, true
+		}
+	}
+	return bw, ok
+}
+
+// byKey sorts paths by their keys.
+type byKey struct {
+	paths []snet.Path
+	keys  []int64
+}
+
+func (b byKey) Len() int           { return len(b.paths) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+
+func (b byKey) Swap(i, j int) {
+	b.paths[i], b.paths[j] = b.paths[j], b.paths[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
@@ -0,0 +1,148 @@
+package pathsel_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+
+	"github.com/tzaeschke/scion-hello/pathsel"
+)
+
+func mustParseIA(s string) addr.IA {
+	ia, err := addr.ParseIA(s)
+	if err != nil {
+		panic(err)
+	}
+	return ia
+}
+
+// testPath returns a path with metadata meta. Its id is the port of the next hop.
+func testPath(id int, meta snet.PathMetadata) snet.Path {
+	return snetpath.Path{
+		NextHop: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: id},
+		Meta:    meta,
+	}
+}
+
+// noMetadata is a path without metadata.
+type noMetadata struct {
+	snetpath.Path
+}
+
+func (noMetadata) Metadata() *snet.PathMetadata { return nil }
+
+// ids returns the ids of paths.
+func ids(paths []snet.Path) []int {
+	res := make([]int, 0, len(paths))
+	for _, p := range paths {
+		res = append(res, p.UnderlayNextHop().Port)
+	}
+	return res
+}
+
+// interfaces returns the interfaces of a path over n inter-AS links.
+func interfaces(n int) []snet.PathInterface {
+	return make([]snet.PathInterface, 2*n)
+}
+
+func ms(ms ...int) []time.Duration {
+	res := make([]time.Duration, 0, len(ms))
+	for _, l := range ms {
+		res = append(res, time.Duration(l)*time.Millisecond)
+	}
+	return res
+}
+
+func TestSort(t *testing.T) {
+	paths := []snet.Path{
+		testPath(1, snet.PathMetadata{
+			Interfaces:   interfaces(3),
+			Latency:      ms(10, 10, 10, 10, 10),
+			Bandwidth:    []uint64{100, 200},
+			MTU:          1400,
+			InternalHops: []uint32{1, 1},
+		}),
+		testPath(2, snet.PathMetadata{
+			Interfaces: interfaces(1),
+			Latency:    []time.Duration{snet.LatencyUnset},
+			Bandwidth:  []uint64{0},
+		}),
+		testPath(3, snet.PathMetadata{
+			Interfaces:   interfaces(2),
+			Latency:      ms(1, 2, 2),
+			Bandwidth:    []uint64{50},
+			MTU:          1500,
+			InternalHops: []uint32{5},
+		}),
+		// Neither a path without interfaces nor one without metadata ranks before the others.
+		testPath(4, snet.PathMetadata{}),
+		noMetadata{snetpath.Path{NextHop: &net.UDPAddr{Port: 5}}},
+	}
+	tests := map[pathsel.Strategy][]int{
+		pathsel.Shortest:  {2, 3, 1, 4, 5},
+		pathsel.Latency:   {3, 1, 2, 4, 5},
+		pathsel.Bandwidth: {1, 3, 2, 4, 5},
+		pathsel.MTU:       {3, 1, 2, 4, 5},
+		pathsel.Hops:      {2, 1, 3, 4, 5},
+	}
+	for s, want := range tests {
+		t.Run(s.String(), func(t *testing.T) {
+			sorted := append([]snet.Path(nil), paths...)
+			pathsel.Sort(sorted, s)
+			if got := ids(sorted); !reflect.DeepEqual(got, want) {
+				t.Errorf("Sort() = %v, want %v", got, want)
+			}
+			if got := pathsel.Best(paths, s).UnderlayNextHop().Port; got != want[0] {
+				t.Errorf("Best() = %d, want %d", got, want[0])
+			}
+			if got := ids(paths); !reflect.DeepEqual(got, []int{1, 2, 3, 4, 5}) {
+				t.Errorf("Best() modified the paths: %v", got)
+			}
+		})
+	}
+}
+
+func TestBestWithoutPaths(t *testing.T) {
+	if p := pathsel.Best(nil, pathsel.Shortest); p != nil {
+		t.Errorf("Best() = %v, want nil", p)
+	}
+}
+
+func TestStrategySet(t *testing.T) {
+	tests := map[string]struct {
+		want pathsel.Strategy
+		err  bool
+	}{
+		"shortest":  {want: pathsel.Shortest},
+		"latency":   {want: pathsel.Latency},
+		"bandwidth": {want: pathsel.Bandwidth},
+		"mtu":       {want: pathsel.MTU},
+		"hops":      {want: pathsel.Hops},
+		"MTU":       {want: pathsel.MTU},
+		"fastest":   {err: true},
+		"":          {err: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := pathsel.Strategy(-1)
+			err := s.Set(name)
+			if tc.err {
+				if err == nil {
+					t.Errorf("Set(%q) = %v, want error", name, s)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s != tc.want {
+				t.Errorf("Set(%q) = %v, want %v", name, s, tc.want)
+			}
+		})
+	}
+}
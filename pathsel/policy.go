@@ -0,0 +1,147 @@
+package pathsel
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/snet"
+	"github.com/scionproto/scion/private/path/pathpol"
+	"gopkg.in/yaml.v2"
+)
+
+// LoadPolicy reads a path policy in the SCION path policy language from a JSON or YAML file.
+// The format is chosen by the file extension, .json or .yaml/.yml. The policy is named after
+// the file:
+//
+//	acl:
+//	  - "- 1-ff00:0:111"
+//	  - "+"
+//	sequence: "1-ff00:0:110 1-ff00:0:112"
+//	options:
+//	  - weight: 1
+//	    policy: {sequence: "1-ff00:0:110#2 0*"}
+//
+// A policy cannot extend other policies.
+func LoadPolicy(file string) (*pathpol.Policy, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, serrors.WrapStr("reading path policy", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".json":
+	case ".yaml", ".yml":
+		if raw, err = yamlToJSON(raw); err != nil {
+			return nil, serrors.WrapStr("parsing path policy", err, "file", file)
+		}
+	default:
+		return nil, serrors.New("unsupported path policy format", "file", file, "ext", ext)
+	}
+	var ext pathpol.ExtPolicy
+	if err := json.Unmarshal(raw, &ext); err != nil {
+		return nil, serrors.WrapStr("parsing path policy", err, "file", file)
+	}
+	policy, err := pathpol.PolicyFromExtPolicy(&ext, nil)
+	if err != nil {
+		return nil, serrors.WrapStr("resolving path policy", err, "file", file)
+	}
+	if err := checkACLs(policy); err != nil {
+		return nil, serrors.WrapStr("invalid path policy", err, "file", file)
+	}
+	policy.Name = filepath.Base(file)
+	return policy, nil
+}
+
+// checkACLs checks that the ACLs of policy and its options end with a default entry, which
+// pathpol requires.
+func checkACLs(policy *pathpol.Policy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.ACL != nil && len(policy.ACL.Entries) > 0 {
+		if _, err := pathpol.NewACL(policy.ACL.Entries...); err != nil {
+			return err
+		}
+	}
+	for _, o := range policy.Options {
+		if o.Policy != nil {
+			if err := checkACLs(o.Policy.Policy); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// allowedPaths returns the fingerprints of paths allowed by the whole policy, including its
+// options. Paths without metadata are not allowed, pathpol cannot evaluate them.
+func allowedPaths(policy *pathpol.Policy, paths []snet.Path) map[snet.PathFingerprint]bool {
+	withMetadata := make([]snet.Path, 0, len(paths))
+	for _, p := range paths {
+		if p.Metadata() != nil {
+			withMetadata = append(withMetadata, p)
+		}
+	}
+	allowed := make(map[snet.PathFingerprint]bool)
+	for _, p := range policy.Filter(withMetadata) {
+		allowed[snet.Fingerprint(p)] = true
+	}
+	return allowed
+}
+
+// checkPolicy returns an ErrExcluded error telling which part of policy excludes path p, nil
+// if p is allowed. Paths without metadata are excluded, as pathpol cannot evaluate them.
+// allowed are the paths allowed by the whole policy, see allowedPaths; the options of a
+// policy depend on the other paths.
+func checkPolicy(policy *pathpol.Policy, p snet.Path, allowed map[snet.PathFingerprint]bool) error {
+	one := []snet.Path{p}
+	switch {
+	case p.Metadata() == nil:
+		return serrors.WithCtx(ErrExcluded, "policy", policy.Name, "by", "metadata")
+	case policy.LocalISDAS != nil && len(policy.LocalISDAS.Eval(one)) == 0:
+		return serrors.WithCtx(ErrExcluded, "policy", policy.Name, "by", "local_isd_ases")
+	case policy.RemoteISDAS != nil && len(policy.RemoteISDAS.Eval(one)) == 0:
+		return serrors.WithCtx(ErrExcluded, "policy", policy.Name, "by", "remote_isd_ases")
+	case len(policy.ACL.Eval(one)) == 0:
+		return serrors.WithCtx(ErrExcluded, "policy", policy.Name, "by", "acl")
+	case len(policy.Sequence.Eval(one)) == 0:
+		return serrors.WithCtx(ErrExcluded, "policy", policy.Name, "by", "sequence",
+			"sequence", policy.Sequence)
+	case !allowed[snet.Fingerprint(p)]:
+		return serrors.WithCtx(ErrExcluded, "policy", policy.Name, "by", "options")
+	}
+	return nil
+}
+
+// yamlToJSON converts a YAML document to JSON, so that it can be decoded with the JSON
+// unmarshalers of pathpol.
+func yamlToJSON(raw []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonValue(v))
+}
+
+// jsonValue converts the map[interface{}]interface{} values produced by yaml.v2 into
+// map[string]interface{}.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,148 @@
+package pathsel_test
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+	"github.com/scionproto/scion/private/path/pathpol"
+
+	"github.com/tzaeschke/scion-hello/pathsel"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	tests := map[string]struct {
+		// ok is set if the file is a valid policy.
+		ok bool
+	}{
+		"acl.yaml":        {ok: true},
+		"acl.json":        {ok: true},
+		"sequence.yml":    {ok: true},
+		"options.yaml":    {ok: true},
+		"no-default.yaml": {},
+		"acl.txt":         {},
+		"missing.yaml":    {},
+	}
+	for file, tc := range tests {
+		t.Run(file, func(t *testing.T) {
+			policy, err := pathsel.LoadPolicy("testdata/" + file)
+			if !tc.ok {
+				if err == nil {
+					t.Errorf("LoadPolicy() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if policy.Name != file {
+				t.Errorf("policy name = %q, want %q", policy.Name, file)
+			}
+		})
+	}
+}
+
+func TestLoadPolicyFormats(t *testing.T) {
+	fromYAML, err := pathsel.LoadPolicy("testdata/acl.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := pathsel.LoadPolicy("testdata/acl.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML.ACL, fromJSON.ACL) {
+		t.Errorf("ACL from YAML = %v, from JSON = %v", fromYAML.ACL, fromJSON.ACL)
+	}
+}
+
+func TestEvaluatePolicy(t *testing.T) {
+	ia110, ia111, ia112 := mustParseIA("1-ff00:0:110"), mustParseIA("1-ff00:0:111"),
+		mustParseIA("1-ff00:0:112")
+	paths := []snet.Path{
+		// Through the core AS 110.
+		testPath(1, snet.PathMetadata{
+			Interfaces: []snet.PathInterface{
+				{IA: ia111, ID: 41}, {IA: ia110, ID: 1}, {IA: ia110, ID: 2}, {IA: ia112, ID: 1},
+			},
+			LinkType: []snet.LinkType{snet.LinkTypeDirect, snet.LinkTypeDirect},
+		}),
+		// Over the direct opennet link from 111 to 112.
+		testPath(2, snet.PathMetadata{
+			Interfaces: []snet.PathInterface{{IA: ia111, ID: 42}, {IA: ia112, ID: 2}},
+			LinkType:   []snet.LinkType{snet.LinkTypeOpennet},
+		}),
+		noMetadata{snetpath.Path{NextHop: &net.UDPAddr{Port: 3}}},
+	}
+	load := func(file string) *pathpol.Policy {
+		policy, err := pathsel.LoadPolicy("testdata/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return policy
+	}
+
+	tests := map[string]struct {
+		sel pathsel.Selector
+		// excluded are the parts that exclude the paths, "" if a path is selected.
+		excluded []string
+	}{
+		"no policy": {
+			excluded: []string{"", "", ""},
+		},
+		"acl": {
+			sel:      pathsel.Selector{Policy: load("acl.yaml")},
+			excluded: []string{"acl", "", "metadata"},
+		},
+		"sequence": {
+			sel:      pathsel.Selector{Policy: load("sequence.yml")},
+			excluded: []string{"sequence", "", "metadata"},
+		},
+		"options": {
+			sel:      pathsel.Selector{Policy: load("options.yaml")},
+			excluded: []string{"options", "", "metadata"},
+		},
+		// The options choose among the paths the filter leaves, so the path through 110 is
+		// the best remaining one.
+		"options after filter": {
+			sel: pathsel.Selector{
+				Filter: pathsel.Filter{LinkTypes: []snet.LinkType{snet.LinkTypeOpennet}},
+				Policy: load("options.yaml"),
+			},
+			excluded: []string{"", "link_type", "metadata"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			selected, decisions := tc.sel.Evaluate(paths)
+			var want []int
+			for i, d := range decisions {
+				if id := d.Path.UnderlayNextHop().Port; id != i+1 {
+					t.Errorf("decision %d is about path %d", i, id)
+				}
+				switch {
+				case tc.excluded[i] == "":
+					if d.Excluded != nil {
+						t.Errorf("path %d excluded: %v", i+1, d.Excluded)
+					}
+					want = append(want, i+1)
+				case !errors.Is(d.Excluded, pathsel.ErrExcluded) ||
+					!strings.Contains(d.Excluded.Error(), tc.excluded[i]):
+
+					t.Errorf("path %d excluded: %v, want by %s", i+1, d.Excluded,
+						tc.excluded[i])
+				}
+			}
+			got := ids(selected)
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("selected %v, want %v", got, want)
+			}
+		})
+	}
+}
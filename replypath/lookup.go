@@ -0,0 +1,177 @@
+package replypath
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+
+	"github.com/tzaeschke/scion-hello/pathsel"
+)
+
+const (
+	// DefaultLookupTimeout is the time a Lookup waits for the daemon by default.
+	DefaultLookupTimeout = time.Second
+	// DefaultCacheTTL is the time a Lookup keeps the chosen path of an ISD-AS by default.
+	DefaultCacheTTL = 30 * time.Second
+	// maxCachedPaths is the number of ISD-ASes a Lookup keeps the chosen path of. Beyond,
+	// the least recently used path is evicted.
+	maxCachedPaths = 1 << 12
+	// maxPendingLookups is the number of ISD-ASes a Lookup queries the paths to at the same
+	// time. Requests from further ISD-ASes are answered over the reversed path without a
+	// lookup.
+	maxPendingLookups = 64
+)
+
+// Lookup chooses reply paths from the paths of the daemon back to the source ISD-AS, ranked
+// by Strategy, instead of reversing the path of the request. It falls back to the reversed
+// path if the lookup fails or finds no path. Packets from the local AS are always answered
+// over the reversed, i.e. empty, path.
+//
+// The paths are queried in the background, so that the caller does not wait for the daemon:
+// until the lookup of an ISD-AS is done, its requests are answered over the reversed path.
+// There is one lookup per ISD-AS at a time. The chosen path of an ISD-AS is cached for
+// CacheTTL, but not beyond its expiry. Failed lookups are cached as well, so that the daemon
+// is not queried for every packet. A Lookup is safe for concurrent use.
+type Lookup struct {
+	// Querier queries the paths, e.g. a daemon.Querier.
+	Querier snet.PathQuerier
+	// Strategy ranks the paths.
+	Strategy pathsel.Strategy
+	// Reverser computes the fallback paths. It also decides whether requests over EPIC paths
+	// are answered at all.
+	Reverser Reverser
+	// Timeout is the time to wait for the paths. If 0, DefaultLookupTimeout is used.
+	Timeout time.Duration
+	// CacheTTL is the time the chosen path of an ISD-AS is reused. If 0, DefaultCacheTTL
+	// is used.
+	CacheTTL time.Duration
+	// Logger logs the chosen paths and failed lookups. If nil, the root logger is used.
+	Logger log.Logger
+
+	mu sync.Mutex
+	// cache maps the ISD-ASes to their elements in lru, the most recently used first.
+	cache   map[addr.IA]*list.Element
+	lru     list.List
+	pending map[addr.IA]struct{}
+	// lookups are the running lookups.
+	lookups sync.WaitGroup
+}
+
+// cachedPath is the chosen path of an ISD-AS, nil if the lookup failed.
+type cachedPath struct {
+	ia      addr.IA
+	path    snet.Path
+	expires time.Time
+}
+
+// ReplyPath implements Pather.
+func (l *Lookup) ReplyPath(ctx context.Context, src addr.IA, dp snet.DataplanePath,
+	lastHop *net.UDPAddr) (snet.DataplanePath, *net.UDPAddr, error) {
+
+	reversed, nextHop, err := l.Reverser.ReplyPath(ctx, src, dp, lastHop)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := reversed.(snetpath.Empty); ok {
+		return reversed, nextHop, nil
+	}
+	p := l.lookup(ctx, src, time.Now())
+	if p == nil {
+		return reversed, nextHop, nil
+	}
+	return p.Dataplane(), p.UnderlayNextHop(), nil
+}
+
+// lookup returns the chosen path to ia at now, nil if there is none yet. If the path is not
+// cached, it starts a lookup unless one is running.
+func (l *Lookup) lookup(ctx context.Context, ia addr.IA, now time.Time) snet.Path {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.cache[ia]; ok {
+		c := e.Value.(*cachedPath)
+		if now.Before(c.expires) {
+			l.lru.MoveToFront(e)
+			return c.path
+		}
+		l.lru.Remove(e)
+		delete(l.cache, ia)
+	}
+	if _, ok := l.pending[ia]; ok || len(l.pending) >= maxPendingLookups {
+		return nil
+	}
+	if l.pending == nil {
+		l.pending = make(map[addr.IA]struct{})
+	}
+	l.pending[ia] = struct{}{}
+	l.lookups.Add(1)
+	// The lookup outlives the request, but keeps the values of ctx, e.g. its span.
+	go l.query(context.WithoutCancel(ctx), ia)
+	return nil
+}
+
+// query looks up the paths to ia and caches the chosen one.
+func (l *Lookup) query(ctx context.Context, ia addr.IA) {
+	defer l.lookups.Done()
+	timeout := l.Timeout
+	if timeout == 0 {
+		timeout = DefaultLookupTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	logger := l.logger()
+	paths, err := l.Querier.Query(ctx, ia)
+	if err != nil {
+		logger.Info("Path lookup failed, replying over reversed paths", "isd_as", ia,
+			"err", err)
+	}
+	c := &cachedPath{
+		ia:      ia,
+		path:    pathsel.Best(paths, l.Strategy),
+		expires: time.Now().Add(l.cacheTTL()),
+	}
+	if c.path != nil {
+		if md := c.path.Metadata(); md != nil && !md.Expiry.IsZero() &&
+			md.Expiry.Before(c.expires) {
+
+			c.expires = md.Expiry
+		}
+		logger.Debug("Chose reply path", "isd_as", ia, "strategy", l.Strategy,
+			"path", c.path)
+	} else if err == nil {
+		logger.Info("No path found, replying over reversed paths", "isd_as", ia)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.pending, ia)
+	if l.cache == nil {
+		l.cache = make(map[addr.IA]*list.Element)
+	}
+	if len(l.cache) >= maxCachedPaths {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.cache, oldest.Value.(*cachedPath).ia)
+	}
+	l.cache[ia] = l.lru.PushFront(c)
+}
+
+func (l *Lookup) cacheTTL() time.Duration {
+	if l.CacheTTL == 0 {
+		return DefaultCacheTTL
+	}
+	return l.CacheTTL
+}
+
+func (l *Lookup) logger() log.Logger {
+	if l.Logger == nil {
+		return log.Root()
+	}
+	return l.Logger
+}
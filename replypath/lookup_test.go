@@ -0,0 +1,266 @@
+package replypath
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/slayers/path"
+	"github.com/scionproto/scion/pkg/slayers/path/scion"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+
+	"github.com/tzaeschke/scion-hello/pathsel"
+)
+
+// querier is a fake snet.PathQuerier. It returns the paths of an ISD-AS, or err, once
+// release is closed, if it is set.
+type querier struct {
+	paths   map[addr.IA][]snet.Path
+	err     error
+	release chan struct{}
+
+	mu      sync.Mutex
+	queries map[addr.IA]int
+}
+
+func (q *querier) Query(ctx context.Context, ia addr.IA) ([]snet.Path, error) {
+	q.mu.Lock()
+	if q.queries == nil {
+		q.queries = make(map[addr.IA]int)
+	}
+	q.queries[ia]++
+	q.mu.Unlock()
+	if q.release != nil {
+		select {
+		case <-q.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return q.paths[ia], q.err
+}
+
+func (q *querier) count(ia addr.IA) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.queries[ia]
+}
+
+func mustParseIA(s string) addr.IA {
+	ia, err := addr.ParseIA(s)
+	if err != nil {
+		panic(err)
+	}
+	return ia
+}
+
+var (
+	ia110 = mustParseIA("1-ff00:0:110")
+	ia111 = mustParseIA("1-ff00:0:111")
+)
+
+// fakePath returns a path to dst over hops AS hops that expires at expiry.
+func fakePath(dst addr.IA, hops int, expiry time.Time) snet.Path {
+	return snetpath.Path{
+		Dst:           dst,
+		DataplanePath: snetpath.SCION{Raw: []byte{byte(hops)}},
+		NextHop:       &net.UDPAddr{IP: net.IPv4(127, 0, 0, byte(hops)), Port: 31000},
+		Meta: snet.PathMetadata{
+			Interfaces: make([]snet.PathInterface, 2*hops),
+			Expiry:     expiry,
+		},
+	}
+}
+
+func TestLookupChoosesAndCaches(t *testing.T) {
+	now := time.Now()
+	expiry := now.Add(time.Hour)
+	q := &querier{paths: map[addr.IA][]snet.Path{
+		ia110: {fakePath(ia110, 3, expiry), fakePath(ia110, 1, expiry)},
+	}}
+	l := &Lookup{Querier: q, Strategy: pathsel.Shortest}
+
+	// The first request starts the lookup and is answered over the reversed path.
+	if p := l.lookup(context.Background(), ia110, now); p != nil {
+		t.Errorf("lookup() before query = %v, want nil", p)
+	}
+	l.lookups.Wait()
+	p := l.lookup(context.Background(), ia110, now)
+	if p == nil || len(p.Metadata().Interfaces) != 2 {
+		t.Fatalf("lookup() = %v, want the shortest path", p)
+	}
+	if n := q.count(ia110); n != 1 {
+		t.Errorf("%d queries, want 1", n)
+	}
+	// After CacheTTL, the path is looked up again.
+	later := now.Add(DefaultCacheTTL + time.Second)
+	if p := l.lookup(context.Background(), ia110, later); p != nil {
+		t.Errorf("lookup() after CacheTTL = %v, want nil", p)
+	}
+	l.lookups.Wait()
+	if n := q.count(ia110); n != 2 {
+		t.Errorf("%d queries, want 2", n)
+	}
+}
+
+func TestLookupCachesUntilPathExpiry(t *testing.T) {
+	now := time.Now()
+	q := &querier{paths: map[addr.IA][]snet.Path{
+		ia110: {fakePath(ia110, 1, now.Add(time.Second))},
+	}}
+	l := &Lookup{Querier: q, CacheTTL: time.Hour}
+	l.lookup(context.Background(), ia110, now)
+	l.lookups.Wait()
+	if p := l.lookup(context.Background(), ia110, now); p == nil {
+		t.Fatal("lookup() = nil, want the path")
+	}
+	if p := l.lookup(context.Background(), ia110, now.Add(2*time.Second)); p != nil {
+		t.Errorf("lookup() after path expiry = %v, want nil", p)
+	}
+}
+
+func TestLookupCachesFailures(t *testing.T) {
+	tests := map[string]*querier{
+		"error":   {err: errors.New("daemon down")},
+		"no path": {},
+	}
+	for name, q := range tests {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			l := &Lookup{Querier: q}
+			for i := 0; i < 3; i++ {
+				if p := l.lookup(context.Background(), ia110, now); p != nil {
+					t.Errorf("lookup() = %v, want nil", p)
+				}
+				l.lookups.Wait()
+			}
+			if n := q.count(ia110); n != 1 {
+				t.Errorf("%d queries, want 1", n)
+			}
+		})
+	}
+}
+
+func TestLookupQueriesOncePerIA(t *testing.T) {
+	now := time.Now()
+	q := &querier{
+		paths:   map[addr.IA][]snet.Path{ia110: {fakePath(ia110, 1, time.Time{})}},
+		release: make(chan struct{}),
+	}
+	l := &Lookup{Querier: q}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The callers do not wait for the blocked query.
+			if p := l.lookup(context.Background(), ia110, now); p != nil {
+				t.Errorf("lookup() = %v, want nil", p)
+			}
+		}()
+	}
+	wg.Wait()
+	close(q.release)
+	l.lookups.Wait()
+	if n := q.count(ia110); n != 1 {
+		t.Errorf("%d queries, want 1", n)
+	}
+	if p := l.lookup(context.Background(), ia110, now); p == nil {
+		t.Error("lookup() = nil, want the path")
+	}
+}
+
+func TestLookupCapsPendingLookups(t *testing.T) {
+	q := &querier{release: make(chan struct{})}
+	l := &Lookup{Querier: q}
+	for i := 0; i < maxPendingLookups+10; i++ {
+		l.lookup(context.Background(), addr.MustIAFrom(1, addr.AS(i+1)), time.Now())
+	}
+	close(q.release)
+	l.lookups.Wait()
+	if n := len(q.queries); n != maxPendingLookups {
+		t.Errorf("%d ISD-ASes queried, want %d", n, maxPendingLookups)
+	}
+}
+
+func TestLookupEvictsLeastRecentlyUsed(t *testing.T) {
+	now := time.Now()
+	q := &querier{}
+	l := &Lookup{Querier: q}
+	ia := func(i int) addr.IA { return addr.MustIAFrom(1, addr.AS(i+1)) }
+	for i := 0; i < maxCachedPaths; i++ {
+		l.lookup(context.Background(), ia(i), now)
+		l.lookups.Wait()
+	}
+	// Using the first ISD-AS makes the second one the least recently used.
+	l.lookup(context.Background(), ia(0), now)
+	l.lookup(context.Background(), ia(maxCachedPaths), now)
+	l.lookups.Wait()
+
+	if n := len(l.cache); n != maxCachedPaths {
+		t.Errorf("%d cached paths, want %d", n, maxCachedPaths)
+	}
+	for i, want := range map[int]bool{0: true, 1: false, 2: true, maxCachedPaths: true} {
+		if _, ok := l.cache[ia(i)]; ok != want {
+			t.Errorf("%v cached = %v, want %v", ia(i), ok, want)
+		}
+	}
+}
+
+func TestLookupReplyPath(t *testing.T) {
+	chosen := fakePath(ia111, 1, time.Time{})
+	q := &querier{paths: map[addr.IA][]snet.Path{ia111: {chosen}}}
+	l := &Lookup{Querier: q}
+	lastHop := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 9), Port: 30041}
+	// A request from 111 over a single segment, as it arrives at the last hop.
+	dec := scion.Decoded{
+		Base: scion.Base{
+			PathMeta: scion.MetaHdr{CurrHF: 1, SegLen: [3]uint8{2}},
+			NumINF:   1,
+			NumHops:  2,
+		},
+		InfoFields: []path.InfoField{{ConsDir: true, Timestamp: 1000}},
+		HopFields:  []path.HopField{{ConsEgress: 1}, {ConsIngress: 2}},
+	}
+	raw := make([]byte, dec.Len())
+	if err := dec.SerializeTo(raw); err != nil {
+		t.Fatal(err)
+	}
+	received := snet.RawPath{PathType: scion.PathType, Raw: raw}
+
+	// Requests from the local AS are answered over the empty path without a lookup.
+	dp, nextHop, err := l.ReplyPath(context.Background(), ia110, snetpath.Empty{}, lastHop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dp.(snetpath.Empty); !ok || nextHop != lastHop {
+		t.Errorf("ReplyPath() = %T %v, want the empty path to %v", dp, nextHop, lastHop)
+	}
+	// Until the lookup is done, requests are answered over the reversed path.
+	dp, nextHop, err = l.ReplyPath(context.Background(), ia111, received, lastHop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dp.(snet.RawReplyPath); !ok || nextHop != lastHop {
+		t.Errorf("ReplyPath() = %T %v, want the reversed path to %v", dp, nextHop, lastHop)
+	}
+	l.lookups.Wait()
+	dp, nextHop, err = l.ReplyPath(context.Background(), ia111, received, lastHop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dp, chosen.Dataplane()) ||
+		nextHop.String() != chosen.UnderlayNextHop().String() {
+
+		t.Errorf("ReplyPath() = %v %v, want the chosen path", dp, nextHop)
+	}
+	if n := q.count(ia110); n != 0 {
+		t.Errorf("%d queries for the local AS, want 0", n)
+	}
+}
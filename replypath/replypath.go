@@ -0,0 +1,165 @@
+// Package replypath computes the paths of replies from the paths of received packets.
+//
+// snet hands out the path of a received packet as an snet.RawPath. A Reverser turns it into
+// the dataplane path of the reply, depending on the path type:
+//
+//   - an empty path, used between hosts of the same AS, is answered with an empty path,
+//   - a SCION path is answered over the reversed path,
+//   - an EPIC path is answered as the EPICPolicy says. A reply cannot be sent over an EPIC
+//     path, because the hop validation fields of the reversed path are unknown to the
+//     replying host. By default, it is sent over the reversed SCION path instead.
+//
+// Other path types, e.g. one-hop paths, are not supported:
+//
+//	rp := replypath.Reverser{EPIC: replypath.EPICReject}
+//	replyPath, err := rp.Reverse(pkt.Path)
+//
+// Instead of reversing, a Lookup answers over a path of its own choice from the daemon, e.g.
+// to study asymmetric routing:
+//
+//	l := &replypath.Lookup{Querier: querier, Strategy: pathsel.Latency}
+//	replyPath, nextHop, err := l.ReplyPath(ctx, pkt.Source.IA, pkt.Path, lastHop)
+package replypath
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/slayers/path"
+	"github.com/scionproto/scion/pkg/slayers/path/empty"
+	"github.com/scionproto/scion/pkg/slayers/path/epic"
+	"github.com/scionproto/scion/pkg/slayers/path/scion"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+)
+
+var (
+	// ErrUnsupported is returned by Reverse for paths of an unsupported type.
+	ErrUnsupported = serrors.New("unsupported path type")
+	// ErrEPICRejected is returned by Reverse for EPIC paths if the policy is EPICReject.
+	ErrEPICRejected = serrors.New("EPIC path rejected by policy")
+)
+
+// EPICPolicy decides how packets received over an EPIC path are answered.
+type EPICPolicy int
+
+const (
+	// EPICAsSCION answers over the reversed SCION path contained in the EPIC path.
+	EPICAsSCION EPICPolicy = iota
+	// EPICReject does not answer, e.g. because the replies should not take a path with
+	// weaker guarantees than the request.
+	EPICReject
+)
+
+// epicPolicies are the names of the EPIC policies, in the order of their values.
+var epicPolicies = []string{"scion", "reject"}
+
+// EPICPolicies returns the names of the EPIC policies.
+func EPICPolicies() []string {
+	return append([]string(nil), epicPolicies...)
+}
+
+func (p EPICPolicy) String() string {
+	if p < 0 || int(p) >= len(epicPolicies) {
+		return "unknown"
+	}
+	return epicPolicies[p]
+}
+
+// Set parses the name of an EPIC policy, so that an EPICPolicy can be used as flag.
+func (p *EPICPolicy) Set(s string) error {
+	for i, name := range epicPolicies {
+		if strings.EqualFold(s, name) {
+			*p = EPICPolicy(i)
+			return nil
+		}
+	}
+	return serrors.New("unknown EPIC policy", "policy", s,
+		"known", strings.Join(epicPolicies, ", "))
+}
+
+// Pather chooses the paths of replies.
+type Pather interface {
+	// ReplyPath returns the path and the underlay next hop of the reply to a packet received
+	// from ISD-AS src over dataplane path dp from underlay address lastHop.
+	ReplyPath(ctx context.Context, src addr.IA, dp snet.DataplanePath,
+		lastHop *net.UDPAddr) (snet.DataplanePath, *net.UDPAddr, error)
+}
+
+// Reverser computes reply paths by reversing the path of the request. The zero value answers
+// EPIC paths over the reversed SCION path.
+type Reverser struct {
+	// EPIC decides how requests received over an EPIC path are answered.
+	EPIC EPICPolicy
+}
+
+// Reverse returns the path of the reply to a packet received over dataplane path dp.
+func (r Reverser) Reverse(dp snet.DataplanePath) (snet.DataplanePath, error) {
+	switch p := dp.(type) {
+	case snet.RawPath:
+		return r.reverseRaw(p.PathType, p.Raw)
+	case snetpath.Empty:
+		return snetpath.Empty{}, nil
+	case snetpath.SCION:
+		// The raw path must not be modified, it may be shared.
+		return reverseSCION(append([]byte(nil), p.Raw...))
+	default:
+		return nil, serrors.WithCtx(ErrUnsupported, "type", common.TypeOf(dp))
+	}
+}
+
+// ReplyPath implements Pather. The reply is sent over the reversed path back to lastHop.
+func (r Reverser) ReplyPath(_ context.Context, _ addr.IA, dp snet.DataplanePath,
+	lastHop *net.UDPAddr) (snet.DataplanePath, *net.UDPAddr, error) {
+
+	reversed, err := r.Reverse(dp)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reversed, lastHop, nil
+}
+
+// reverseRaw reverses the raw path of type t. It may modify raw.
+func (r Reverser) reverseRaw(t path.Type, raw []byte) (snet.DataplanePath, error) {
+	switch t {
+	case empty.PathType:
+		if len(raw) != 0 {
+			return nil, serrors.New("non-empty raw empty path", "len", len(raw))
+		}
+		return snetpath.Empty{}, nil
+	case scion.PathType:
+		return reverseSCION(raw)
+	case epic.PathType:
+		if r.EPIC == EPICReject {
+			return nil, ErrEPICRejected
+		}
+		var p epic.Path
+		if err := p.DecodeFromBytes(raw); err != nil {
+			return nil, serrors.WrapStr("decoding EPIC path", err)
+		}
+		return reverse(p.ScionPath)
+	default:
+		return nil, serrors.WithCtx(ErrUnsupported, "type", t)
+	}
+}
+
+// reverseSCION reverses raw SCION path raw in place.
+func reverseSCION(raw []byte) (snet.DataplanePath, error) {
+	var p scion.Raw
+	if err := p.DecodeFromBytes(raw); err != nil {
+		return nil, serrors.WrapStr("decoding SCION path", err)
+	}
+	return reverse(&p)
+}
+
+func reverse(p *scion.Raw) (snet.DataplanePath, error) {
+	reversed, err := p.Reverse()
+	if err != nil {
+		return nil, serrors.WrapStr("reversing SCION path", err)
+	}
+	return snet.RawReplyPath{Path: reversed}, nil
+}
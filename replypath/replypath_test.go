@@ -0,0 +1,170 @@
+package replypath_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/scionproto/scion/pkg/slayers/path"
+	"github.com/scionproto/scion/pkg/slayers/path/empty"
+	"github.com/scionproto/scion/pkg/slayers/path/epic"
+	"github.com/scionproto/scion/pkg/slayers/path/onehop"
+	"github.com/scionproto/scion/pkg/slayers/path/scion"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+
+	"github.com/tzaeschke/scion-hello/replypath"
+)
+
+var (
+	lastHop = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 9), Port: 30041}
+
+	hops = []path.HopField{
+		{ExpTime: 63, ConsIngress: 0, ConsEgress: 41, Mac: [path.MacLen]byte{1}},
+		{ExpTime: 63, ConsIngress: 1, ConsEgress: 0, Mac: [path.MacLen]byte{2}},
+		{ExpTime: 63, ConsIngress: 0, ConsEgress: 2, Mac: [path.MacLen]byte{3}},
+		{ExpTime: 63, ConsIngress: 1, ConsEgress: 0, Mac: [path.MacLen]byte{4}},
+	}
+	up   = path.InfoField{ConsDir: false, SegID: 0x111, Timestamp: 1000}
+	down = path.InfoField{ConsDir: true, SegID: 0x222, Timestamp: 2000}
+)
+
+// received returns the raw SCION path of an up and a down segment, as it arrives at the
+// destination: the current hop field is the last one.
+func received(t *testing.T) []byte {
+	t.Helper()
+	dec := scion.Decoded{
+		Base: scion.Base{
+			PathMeta: scion.MetaHdr{CurrINF: 1, CurrHF: 3, SegLen: [3]uint8{2, 2}},
+			NumINF:   2,
+			NumHops:  4,
+		},
+		InfoFields: []path.InfoField{up, down},
+		HopFields:  hops,
+	}
+	raw := make([]byte, dec.Len())
+	if err := dec.SerializeTo(raw); err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// receivedEPIC returns the raw EPIC path around the path of received.
+func receivedEPIC(t *testing.T) []byte {
+	t.Helper()
+	var sp scion.Raw
+	if err := sp.DecodeFromBytes(received(t)); err != nil {
+		t.Fatal(err)
+	}
+	p := epic.Path{
+		PktID:     epic.PktID{Timestamp: 1, Counter: 2},
+		PHVF:      make([]byte, epic.HVFLen),
+		LHVF:      make([]byte, epic.HVFLen),
+		ScionPath: &sp,
+	}
+	raw := make([]byte, p.Len())
+	if err := p.SerializeTo(raw); err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestReverserReplyPath(t *testing.T) {
+	// The reply takes the down segment up and the up segment down, starting at the first hop.
+	wantSCION := &scion.Decoded{
+		Base: scion.Base{
+			PathMeta: scion.MetaHdr{CurrINF: 0, CurrHF: 0, SegLen: [3]uint8{2, 2}},
+			NumINF:   2,
+			NumHops:  4,
+		},
+		InfoFields: []path.InfoField{
+			{ConsDir: false, SegID: down.SegID, Timestamp: down.Timestamp},
+			{ConsDir: true, SegID: up.SegID, Timestamp: up.Timestamp},
+		},
+		HopFields: []path.HopField{hops[3], hops[2], hops[1], hops[0]},
+	}
+
+	tests := map[string]struct {
+		policy replypath.EPICPolicy
+		dp     snet.DataplanePath
+		// want is the decoded SCION path of the reply, nil for an empty path.
+		want *scion.Decoded
+		err  error
+	}{
+		"empty path": {
+			dp: snetpath.Empty{},
+		},
+		"raw empty path": {
+			dp: snet.RawPath{PathType: empty.PathType},
+		},
+		"SCION path": {
+			dp:   snet.RawPath{PathType: scion.PathType, Raw: received(t)},
+			want: wantSCION,
+		},
+		"EPIC path as SCION": {
+			policy: replypath.EPICAsSCION,
+			dp:     snet.RawPath{PathType: epic.PathType, Raw: receivedEPIC(t)},
+			want:   wantSCION,
+		},
+		"EPIC path rejected": {
+			policy: replypath.EPICReject,
+			dp:     snet.RawPath{PathType: epic.PathType, Raw: receivedEPIC(t)},
+			err:    replypath.ErrEPICRejected,
+		},
+		"unsupported path type": {
+			dp:  snet.RawPath{PathType: onehop.PathType, Raw: make([]byte, onehop.PathLen)},
+			err: replypath.ErrUnsupported,
+		},
+		"unsupported dataplane path": {
+			dp:  snetpath.OneHop{},
+			err: replypath.ErrUnsupported,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := replypath.Reverser{EPIC: tc.policy}
+			dp, nextHop, err := r.ReplyPath(context.Background(), 0, tc.dp, lastHop)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("error = %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if nextHop != lastHop {
+				t.Errorf("next hop = %v, want the last hop %v", nextHop, lastHop)
+			}
+			if tc.want == nil {
+				if _, ok := dp.(snetpath.Empty); !ok {
+					t.Errorf("reply path is %T, want snetpath.Empty", dp)
+				}
+				return
+			}
+			if got := decode(t, dp); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("reply path = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+// decode returns the decoded SCION path of reply path dp.
+func decode(t *testing.T, dp snet.DataplanePath) *scion.Decoded {
+	t.Helper()
+	rp, ok := dp.(snet.RawReplyPath)
+	if !ok || rp.Path.Type() != scion.PathType {
+		t.Fatalf("reply path is %T, want a SCION snet.RawReplyPath", dp)
+	}
+	raw := make([]byte, rp.Path.Len())
+	if err := rp.Path.SerializeTo(raw); err != nil {
+		t.Fatal(err)
+	}
+	var dec scion.Decoded
+	if err := dec.DecodeFromBytes(raw); err != nil {
+		t.Fatal(err)
+	}
+	return &dec
+}
@@ -0,0 +1,370 @@
+// Package router emulates a SCION border router on a loopback underlay, so that raw SCION
+// packets can be forwarded without the SCION stack.
+//
+// The emulator handles the border routers of one AS. It parses the SCION header, checks the
+// current hop field and, if a key is configured, its MAC, advances the hop field pointers and
+// either forwards the packet to the router of the neighbor AS or delivers it to the
+// destination end host:
+//
+//	r := &router.Router{IA: ia, Key: key, Interfaces: neighbors}
+//	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 9), Port: 31002})
+//	err = r.Serve(ctx, conn)
+//
+// A link to the own underlay address loops the packet back into the same router, which
+// allows forwarding over a path with a single router.
+package router
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/log"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/private/serrors"
+	"github.com/scionproto/scion/pkg/scrypto"
+	"github.com/scionproto/scion/pkg/slayers"
+	"github.com/scionproto/scion/pkg/slayers/path"
+	"github.com/scionproto/scion/pkg/slayers/path/empty"
+	"github.com/scionproto/scion/pkg/slayers/path/scion"
+	"github.com/scionproto/scion/private/topology"
+)
+
+// The pause after a failed read doubles with every consecutive failure, from minReadBackoff up
+// to maxReadBackoff, so that a persistent error does not make the router spin.
+const (
+	minReadBackoff = time.Millisecond
+	maxReadBackoff = time.Second
+)
+
+// Stats are the packet counters of a Router.
+type Stats struct {
+	// Forwarded is the number of packets sent to a neighbor router.
+	Forwarded uint64
+	// Delivered is the number of packets sent to a local end host.
+	Delivered uint64
+	// Dropped is the number of packets that could not be processed.
+	Dropped uint64
+}
+
+// Router is an emulated border router of AS IA.
+type Router struct {
+	// IA is the ISD-AS of the router.
+	IA addr.IA
+	// Key is the hop field MAC key of the AS. If empty, MACs are not verified.
+	Key []byte
+	// Interfaces maps the interface IDs of the AS to the underlay address of the router on
+	// the other side of the link.
+	Interfaces map[common.IFIDType]*net.UDPAddr
+	// EndHostPort is the port packets are delivered to on the end host. If 0, it is the
+	// dispatcher port 30041.
+	EndHostPort int
+	// L4Port delivers UDP packets to their destination port instead of EndHostPort, as a
+	// router for dispatcher-less end hosts does. SCMP echo and traceroute replies are
+	// delivered to the port given by their identifier, SCMP errors to the port of the packet
+	// that caused them.
+	L4Port bool
+	// Logger is used for per-packet messages. If nil, the root logger is used.
+	Logger log.Logger
+
+	forwarded atomic.Uint64
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// Serve reads packets from conn and forwards them until ctx is done or conn fails
+// permanently, e.g. because it was closed. It returns ctx.Err() if ctx is done. Serve can be
+// called concurrently for several connections.
+func (r *Router) Serve(ctx context.Context, conn *net.UDPConn) error {
+	// A hash.Hash is not safe for concurrent use, so every Serve call has its own.
+	mac, err := r.newMAC()
+	if err != nil {
+		return err
+	}
+	logger := r.logger()
+	// Unblock the pending read once ctx is done.
+	stop := context.AfterFunc(ctx, func() {
+		if err := conn.SetReadDeadline(time.Now()); err != nil {
+			logger.Error("Failed to interrupt read", "err", err)
+		}
+	})
+	defer stop()
+
+	buf := make([]byte, common.SupportedMTU)
+	var backoff time.Duration
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return serrors.WrapStr("reading packet", err)
+			}
+			r.dropped.Add(1)
+			backoff = min(max(2*backoff, minReadBackoff), maxReadBackoff)
+			logger.Error("Failed to read packet", "err", err, "retry_in", backoff)
+			t := time.NewTimer(backoff)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			}
+			continue
+		}
+		backoff = 0
+		next, local, err := r.process(buf[:n], mac)
+		if err != nil {
+			r.dropped.Add(1)
+			logger.Info("Dropped packet", "src", src, "err", err)
+			continue
+		}
+		if _, err := conn.WriteToUDP(buf[:n], next); err != nil {
+			r.dropped.Add(1)
+			logger.Error("Failed to send packet", "next_hop", next, "err", err)
+			continue
+		}
+		if local {
+			r.delivered.Add(1)
+		} else {
+			r.forwarded.Add(1)
+		}
+		logger.Debug("Forwarded packet", "src", src, "next_hop", next, "local", local)
+	}
+}
+
+// Stats returns a snapshot of the packet counters.
+func (r *Router) Stats() Stats {
+	return Stats{
+		Forwarded: r.forwarded.Load(),
+		Delivered: r.delivered.Load(),
+		Dropped:   r.dropped.Load(),
+	}
+}
+
+// newMAC returns the hash to verify hop field MACs with, or nil if no key is set.
+func (r *Router) newMAC() (hash.Hash, error) {
+	if len(r.Key) == 0 {
+		return nil, nil
+	}
+	mac, err := scrypto.InitMac(r.Key)
+	if err != nil {
+		return nil, serrors.WrapStr("initializing MAC", err)
+	}
+	return mac, nil
+}
+
+// process updates the SCION packet in raw in place, verifying hop field MACs with mac unless
+// it is nil. It returns the underlay address to send the packet to and whether it is a local
+// end host.
+func (r *Router) process(raw []byte, mac hash.Hash) (*net.UDPAddr, bool, error) {
+	var s slayers.SCION
+	if err := s.DecodeFromBytes(raw, gopacket.NilDecodeFeedback); err != nil {
+		return nil, false, serrors.WrapStr("parsing SCION header", err)
+	}
+	switch p := s.Path.(type) {
+	case empty.Path:
+		if !s.SrcIA.Equal(r.IA) || !s.DstIA.Equal(r.IA) {
+			return nil, false, serrors.New("empty path outside of local AS",
+				"src", s.SrcIA, "dst", s.DstIA)
+		}
+		return r.deliver(&s)
+	case *scion.Raw:
+		egress, err := processHop(p, mac)
+		if err != nil {
+			return nil, false, err
+		}
+		if egress == 0 {
+			if !s.DstIA.Equal(r.IA) {
+				return nil, false, serrors.New("path ends outside of destination AS",
+					"dst", s.DstIA)
+			}
+			return r.deliver(&s)
+		}
+		next, ok := r.Interfaces[egress]
+		if !ok {
+			return nil, false, serrors.New("unknown egress interface", "ifid", egress)
+		}
+		return next, false, nil
+	default:
+		return nil, false, serrors.New("unsupported path type", "type", s.PathType)
+	}
+}
+
+// processHop checks the current hop field and moves p to the hop field of the next AS. It
+// returns the egress interface, which is 0 if the packet is at the last hop.
+func processHop(p *scion.Raw, mac hash.Hash) (common.IFIDType, error) {
+	info, hf, err := current(p)
+	if err != nil {
+		return 0, err
+	}
+	ingress, egress := travelInterfaces(info, hf)
+	// Against construction direction, the SegID is updated on ingress.
+	if ingress != 0 && !info.ConsDir {
+		info.SegID ^= binary.BigEndian.Uint16(hf.Mac[:2])
+		if err := p.SetInfoField(info, int(p.PathMeta.CurrINF)); err != nil {
+			return 0, serrors.WrapStr("updating info field", err)
+		}
+	}
+	if err := verify(mac, info, hf); err != nil {
+		return 0, err
+	}
+	// Segment crossover: continue with the first hop field of the next segment.
+	if egress == 0 && !p.IsLastHop() {
+		if err := p.IncPath(); err != nil {
+			return 0, serrors.WrapStr("crossing over", err)
+		}
+		if info, hf, err = current(p); err != nil {
+			return 0, err
+		}
+		if err := verify(mac, info, hf); err != nil {
+			return 0, err
+		}
+		_, egress = travelInterfaces(info, hf)
+	}
+	if egress == 0 {
+		return 0, nil
+	}
+	// In construction direction, the SegID is updated on egress.
+	if info.ConsDir {
+		info.SegID ^= binary.BigEndian.Uint16(hf.Mac[:2])
+		if err := p.SetInfoField(info, int(p.PathMeta.CurrINF)); err != nil {
+			return 0, serrors.WrapStr("updating info field", err)
+		}
+	}
+	if err := p.IncPath(); err != nil {
+		return 0, serrors.WrapStr("advancing path", err)
+	}
+	return egress, nil
+}
+
+// verify checks that hop field hf is not expired and, unless mac is nil, its MAC.
+func verify(mac hash.Hash, info path.InfoField, hf path.HopField) error {
+	expiry := time.Unix(int64(info.Timestamp), 0).Add(path.ExpTimeToDuration(hf.ExpTime))
+	if time.Now().After(expiry) {
+		return serrors.New("expired hop field", "expiry", expiry)
+	}
+	if mac == nil {
+		return nil
+	}
+	want := path.MAC(mac, info, hf, nil)
+	if subtle.ConstantTimeCompare(want[:], hf.Mac[:]) == 0 {
+		return serrors.New("invalid hop field MAC", "ingress", hf.ConsIngress,
+			"egress", hf.ConsEgress)
+	}
+	return nil
+}
+
+// deliver returns the underlay address of the destination end host of s.
+func (r *Router) deliver(s *slayers.SCION) (*net.UDPAddr, bool, error) {
+	dst, err := s.DstAddr()
+	if err != nil {
+		return nil, false, serrors.WrapStr("parsing destination address", err)
+	}
+	if dst.Type() != addr.HostTypeIP {
+		return nil, false, serrors.New("unsupported destination address", "dst", dst)
+	}
+	port := r.EndHostPort
+	if port == 0 {
+		port = topology.EndhostPort
+	}
+	if r.L4Port {
+		if p, ok := l4Port(s.NextHdr, s.Payload); ok {
+			port = p
+		}
+	}
+	return net.UDPAddrFromAddrPort(netip.AddrPortFrom(dst.IP(), uint16(port))), true, nil
+}
+
+// l4Port returns the port of the end host that receives a packet with the layer 4 protocol
+// proto and payload, if it is determined by the payload.
+func l4Port(proto slayers.L4ProtocolType, payload []byte) (int, bool) {
+	switch proto {
+	case slayers.L4UDP:
+		if len(payload) < 4 {
+			return 0, false
+		}
+		return int(binary.BigEndian.Uint16(payload[2:4])), true
+	case slayers.L4SCMP:
+		// The SCMP header is followed by the identifier of echo and traceroute messages.
+		if len(payload) < 6 {
+			return 0, false
+		}
+		switch t := slayers.SCMPType(payload[0]); t {
+		case slayers.SCMPTypeEchoReply, slayers.SCMPTypeTracerouteReply:
+			return int(binary.BigEndian.Uint16(payload[4:6])), true
+		case slayers.SCMPTypeEchoRequest, slayers.SCMPTypeTracerouteRequest:
+			return 0, false
+		default:
+			// Errors have another 4 bytes before the quoted packet.
+			if slayers.CreateSCMPTypeCode(t, 0).InfoMsg() || len(payload) < 8 {
+				return 0, false
+			}
+			return quotedPort(payload[8:])
+		}
+	}
+	return 0, false
+}
+
+// quotedPort returns the source port of the packet quoted in an SCMP error message, i.e.
+// the port the sender of the packet receives on.
+func quotedPort(quote []byte) (int, bool) {
+	var s slayers.SCION
+	if err := s.DecodeFromBytes(quote, gopacket.NilDecodeFeedback); err != nil {
+		return 0, false
+	}
+	switch s.NextHdr {
+	case slayers.L4UDP:
+		if len(s.Payload) < 2 {
+			return 0, false
+		}
+		return int(binary.BigEndian.Uint16(s.Payload[0:2])), true
+	case slayers.L4SCMP:
+		if len(s.Payload) < 6 {
+			return 0, false
+		}
+		switch slayers.SCMPType(s.Payload[0]) {
+		case slayers.SCMPTypeEchoRequest, slayers.SCMPTypeTracerouteRequest:
+			return int(binary.BigEndian.Uint16(s.Payload[4:6])), true
+		}
+	}
+	return 0, false
+}
+
+func (r *Router) logger() log.Logger {
+	if r.Logger != nil {
+		return r.Logger
+	}
+	return log.Root()
+}
+
+func current(p *scion.Raw) (path.InfoField, path.HopField, error) {
+	info, err := p.GetCurrentInfoField()
+	if err != nil {
+		return path.InfoField{}, path.HopField{}, serrors.WrapStr("reading info field", err)
+	}
+	hf, err := p.GetCurrentHopField()
+	if err != nil {
+		return path.InfoField{}, path.HopField{}, serrors.WrapStr("reading hop field", err)
+	}
+	return info, hf, nil
+}
+
+// travelInterfaces returns the ingress and egress interface of hf in travel direction.
+func travelInterfaces(info path.InfoField, hf path.HopField) (common.IFIDType,
+	common.IFIDType) {
+
+	if info.ConsDir {
+		return common.IFIDType(hf.ConsIngress), common.IFIDType(hf.ConsEgress)
+	}
+	return common.IFIDType(hf.ConsEgress), common.IFIDType(hf.ConsIngress)
+}
@@ -0,0 +1,472 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/scrypto"
+	"github.com/scionproto/scion/pkg/slayers"
+	"github.com/scionproto/scion/pkg/slayers/path"
+	"github.com/scionproto/scion/pkg/slayers/path/scion"
+	"github.com/scionproto/scion/pkg/snet"
+	snetpath "github.com/scionproto/scion/pkg/snet/path"
+)
+
+// The test topology has the core AS 110 and the ASes 111 and 112, connected by the links
+// 110#1-111#41, 111#42-112#2 and 110#2-112#1.
+var (
+	ia110 = mustParseIA("1-ff00:0:110")
+	ia111 = mustParseIA("1-ff00:0:111")
+	ia112 = mustParseIA("1-ff00:0:112")
+
+	keys = map[addr.IA][]byte{
+		ia110: []byte("0110011001100110"),
+		ia111: []byte("0111011101110111"),
+		ia112: []byte("0112011201120112"),
+	}
+	underlay = map[addr.IA]*net.UDPAddr{
+		ia110: {IP: net.IPv4(127, 0, 0, 10), Port: 31000},
+		ia111: {IP: net.IPv4(127, 0, 0, 11), Port: 31000},
+		ia112: {IP: net.IPv4(127, 0, 0, 12), Port: 31000},
+	}
+	links = map[addr.IA]map[common.IFIDType]addr.IA{
+		ia110: {1: ia111, 2: ia112},
+		ia111: {41: ia110, 42: ia112},
+		ia112: {1: ia110, 2: ia111},
+	}
+
+	srcIP = netip.MustParseAddr("127.0.0.2")
+	dstIP = netip.MustParseAddr("127.0.0.1")
+)
+
+func mustParseIA(s string) addr.IA {
+	ia, err := addr.ParseIA(s)
+	if err != nil {
+		panic(err)
+	}
+	return ia
+}
+
+// routers returns the routers of the test topology by underlay address.
+func routers() map[string]*Router {
+	res := make(map[string]*Router)
+	for ia, l := range links {
+		r := &Router{IA: ia, Key: keys[ia], Interfaces: map[common.IFIDType]*net.UDPAddr{},
+			L4Port: true}
+		for id, neighbor := range l {
+			r.Interfaces[id] = underlay[neighbor]
+		}
+		res[underlay[ia].String()] = r
+	}
+	return res
+}
+
+// hop is an AS on a segment, with its interfaces in construction direction.
+type hop struct {
+	ia      addr.IA
+	in, out uint16
+}
+
+// segment returns the info and hop fields of a segment constructed through hops, in travel
+// direction. The hop fields expire after lifetime, counted from ts.
+func segment(t *testing.T, consDir bool, ts time.Time, lifetime time.Duration,
+	hops ...hop) (path.InfoField, []path.HopField) {
+
+	t.Helper()
+	expTime, err := path.ExpTimeFromDuration(lifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := path.InfoField{ConsDir: consDir, SegID: 0x1234, Timestamp: uint32(ts.Unix())}
+	hfs := make([]path.HopField, len(hops))
+	segID := info.SegID
+	for i, h := range hops {
+		hfs[i] = path.HopField{ExpTime: expTime, ConsIngress: h.in, ConsEgress: h.out}
+		mac, err := scrypto.InitMac(keys[h.ia])
+		if err != nil {
+			t.Fatal(err)
+		}
+		hfs[i].Mac = path.MAC(mac, path.InfoField{SegID: segID, Timestamp: info.Timestamp},
+			hfs[i], nil)
+		segID ^= uint16(hfs[i].Mac[0])<<8 | uint16(hfs[i].Mac[1])
+	}
+	if consDir {
+		return info, hfs
+	}
+	// Against construction direction, the hop fields are traversed in reverse and the SegID
+	// starts with the value used for the last hop in construction direction.
+	for i, j := 0, len(hfs)-1; i < j; i, j = i+1, j-1 {
+		hfs[i], hfs[j] = hfs[j], hfs[i]
+	}
+	info.SegID = segID ^ (uint16(hfs[0].Mac[0])<<8 | uint16(hfs[0].Mac[1]))
+	return info, hfs
+}
+
+// seg is a segment as returned by segment.
+type seg struct {
+	info path.InfoField
+	hfs  []path.HopField
+}
+
+// dataplane serializes a SCION path of segs.
+func dataplane(t *testing.T, segs ...seg) snet.DataplanePath {
+	t.Helper()
+	dec := scion.Decoded{Base: scion.Base{NumINF: len(segs)}}
+	for i, s := range segs {
+		dec.PathMeta.SegLen[i] = uint8(len(s.hfs))
+		dec.NumHops += len(s.hfs)
+		dec.InfoFields = append(dec.InfoFields, s.info)
+		dec.HopFields = append(dec.HopFields, s.hfs...)
+	}
+	raw := make([]byte, dec.Len())
+	if err := dec.SerializeTo(raw); err != nil {
+		t.Fatal(err)
+	}
+	return snetpath.SCION{Raw: raw}
+}
+
+// packet returns a serialized UDP packet from src to dst over dp.
+func packet(t *testing.T, src, dst addr.IA, dp snet.DataplanePath) []byte {
+	t.Helper()
+	pkt := &snet.Packet{PacketInfo: snet.PacketInfo{
+		Source:      snet.SCIONAddress{IA: src, Host: addr.HostIP(srcIP)},
+		Destination: snet.SCIONAddress{IA: dst, Host: addr.HostIP(dstIP)},
+		Path:        dp,
+		Payload:     snet.UDPPayload{SrcPort: 40000, DstPort: 8080, Payload: []byte("hello")},
+	}}
+	if err := pkt.Serialize(); err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte(nil), pkt.Bytes...)
+}
+
+// route forwards raw through the routers, starting with the router of src, until it is
+// delivered. It returns the end host address and the ASes of the routers on the way.
+func route(t *testing.T, src addr.IA, raw []byte) (*net.UDPAddr, []addr.IA, error) {
+	t.Helper()
+	rs := routers()
+	r := rs[underlay[src].String()]
+	var ases []addr.IA
+	for len(ases) < 10 {
+		ases = append(ases, r.IA)
+		mac, err := r.newMAC()
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, local, err := r.process(raw, mac)
+		if err != nil || local {
+			return next, ases, err
+		}
+		if r = rs[next.String()]; r == nil {
+			t.Fatalf("forwarded to unknown router %v", next)
+		}
+	}
+	t.Fatalf("packet loops: %v", ases)
+	return nil, nil, nil
+}
+
+func TestProcess(t *testing.T) {
+	now := time.Now()
+	// The segment from the core AS 110 over 111 to 112.
+	multiHop := []hop{{ia110, 0, 1}, {ia111, 41, 42}, {ia112, 2, 0}}
+	badMAC := func(s seg) seg {
+		s.hfs = append([]path.HopField(nil), s.hfs...)
+		s.hfs[1].Mac[0] ^= 0xff
+		return s
+	}
+	mk := func(consDir bool, ts time.Time, lifetime time.Duration, hops ...hop) seg {
+		info, hfs := segment(t, consDir, ts, lifetime, hops...)
+		return seg{info, hfs}
+	}
+	endHost := &net.UDPAddr{IP: dstIP.AsSlice(), Port: 8080}
+
+	tests := map[string]struct {
+		src, dst addr.IA
+		dp       snet.DataplanePath
+		want     []addr.IA
+		err      string
+	}{
+		"multi-hop in construction direction": {
+			src:  ia110,
+			dst:  ia112,
+			dp:   dataplane(t, mk(true, now, time.Hour, multiHop...)),
+			want: []addr.IA{ia110, ia111, ia112},
+		},
+		"against construction direction": {
+			src:  ia112,
+			dst:  ia110,
+			dp:   dataplane(t, mk(false, now, time.Hour, multiHop...)),
+			want: []addr.IA{ia112, ia111, ia110},
+		},
+		"crossover": {
+			src: ia111,
+			dst: ia112,
+			dp: dataplane(t,
+				mk(false, now, time.Hour, hop{ia110, 0, 1}, hop{ia111, 41, 0}),
+				mk(true, now, time.Hour, hop{ia110, 0, 2}, hop{ia112, 1, 0})),
+			want: []addr.IA{ia111, ia110, ia112},
+		},
+		"bad MAC": {
+			src:  ia110,
+			dst:  ia112,
+			dp:   dataplane(t, badMAC(mk(true, now, time.Hour, multiHop...))),
+			want: []addr.IA{ia110, ia111},
+			err:  "invalid hop field MAC",
+		},
+		"expired hop": {
+			src:  ia110,
+			dst:  ia112,
+			dp:   dataplane(t, mk(true, now.Add(-time.Hour), 30*time.Minute, multiHop...)),
+			want: []addr.IA{ia110},
+			err:  "expired hop field",
+		},
+		"empty intra-AS path": {
+			src:  ia111,
+			dst:  ia111,
+			dp:   snetpath.Empty{},
+			want: []addr.IA{ia111},
+		},
+		"empty path to other AS": {
+			src:  ia111,
+			dst:  ia112,
+			dp:   snetpath.Empty{},
+			want: []addr.IA{ia111},
+			err:  "empty path outside of local AS",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			next, ases, err := route(t, tc.src, packet(t, tc.src, tc.dst, tc.dp))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("error = %v, want %q", err, tc.err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if next.String() != endHost.String() {
+				t.Errorf("delivered to %v, want %v", next, endHost)
+			}
+			if !equalIAs(ases, tc.want) {
+				t.Errorf("routers %v, want %v", ases, tc.want)
+			}
+		})
+	}
+}
+
+func equalIAs(a, b []addr.IA) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestServeConcurrently serves two connections with the same router, which must not share
+// the MAC state.
+func TestServeConcurrently(t *testing.T) {
+	recv, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recv.Close()
+	r := &Router{IA: ia110, Key: keys[ia110],
+		Interfaces: map[common.IFIDType]*net.UDPAddr{1: recv.LocalAddr().(*net.UDPAddr)}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var conns []*net.UDPConn
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+		go func() { errs <- r.Serve(ctx, conn) }()
+	}
+
+	info, hfs := segment(t, true, time.Now(), time.Hour, hop{ia110, 0, 1}, hop{ia111, 41, 0})
+	raw := packet(t, ia110, ia111, dataplane(t, seg{info, hfs}))
+	const n = 50
+	for i := 0; i < n; i++ {
+		for _, conn := range conns {
+			if _, err := recv.WriteToUDP(raw, conn.LocalAddr().(*net.UDPAddr)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	buf := make([]byte, common.SupportedMTU)
+	for i := 0; i < 2*n; i++ {
+		if err := recv.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := recv.ReadFromUDP(buf); err != nil {
+			t.Fatalf("received %d of %d packets: %v", i, 2*n, err)
+		}
+	}
+
+	cancel()
+	for range conns {
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("Serve() = %v, want %v", err, context.Canceled)
+		}
+	}
+	if s := r.Stats(); s != (Stats{Forwarded: 2 * n}) {
+		t.Errorf("Stats() = %+v, want %d forwarded", s, 2*n)
+	}
+}
+
+// TestServeBacksOffOnReadErrors serves a connection whose reads always fail. The router must
+// not spin on the error and must still stop with its context.
+func TestServeBacksOffOnReadErrors(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// A deadline in the past makes every read fail.
+	if err := conn.SetReadDeadline(time.Unix(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	r := &Router{IA: ia110}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Serve(ctx, conn) }()
+
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Serve() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("router did not stop during the backoff")
+	}
+	// The backoff doubles from 1ms, so there are about 8 reads in 200ms.
+	if dropped := r.Stats().Dropped; dropped == 0 || dropped > 20 {
+		t.Errorf("%d failed reads, want a few", dropped)
+	}
+}
+
+func TestL4Port(t *testing.T) {
+	quote := func(pld snet.Payload) []byte {
+		pkt := &snet.Packet{PacketInfo: snet.PacketInfo{
+			Source:      snet.SCIONAddress{IA: ia111, Host: addr.HostIP(srcIP)},
+			Destination: snet.SCIONAddress{IA: ia112, Host: addr.HostIP(dstIP)},
+			Path:        snetpath.Empty{},
+			Payload:     pld,
+		}}
+		if err := pkt.Serialize(); err != nil {
+			t.Fatal(err)
+		}
+		return append([]byte(nil), pkt.Bytes...)
+	}
+	// scmp returns an SCMP message of type t. Informational messages start with id, errors
+	// quote the packet q.
+	scmp := func(t slayers.SCMPType, id uint16, q []byte) []byte {
+		msg := []byte{byte(t), 0, 0, 0, byte(id >> 8), byte(id), 0, 0}
+		return append(msg, q...)
+	}
+	udpQuote := quote(snet.UDPPayload{SrcPort: 40000, DstPort: 8080})
+	echoQuote := quote(snet.SCMPEchoRequest{Identifier: 40001})
+	tracerouteQuote := quote(snet.SCMPTracerouteRequest{Identifier: 40002})
+	echoReplyQuote := quote(snet.SCMPEchoReply{Identifier: 40003})
+
+	tests := map[string]struct {
+		proto   slayers.L4ProtocolType
+		payload []byte
+		// want is the port, 0 if there is none.
+		want int
+	}{
+		"UDP": {
+			proto:   slayers.L4UDP,
+			payload: []byte{0x9c, 0x40, 0x1f, 0x90},
+			want:    8080,
+		},
+		"truncated UDP": {proto: slayers.L4UDP, payload: []byte{0x9c, 0x40, 0x1f}},
+		"echo reply": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypeEchoReply, 40001, nil),
+			want:    40001,
+		},
+		"traceroute reply": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypeTracerouteReply, 40002, nil),
+			want:    40002,
+		},
+		"truncated echo reply": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypeEchoReply, 40001, nil)[:5],
+		},
+		"echo request": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypeEchoRequest, 40001, nil),
+		},
+		"traceroute request": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypeTracerouteRequest, 40002, nil),
+		},
+		"unknown informational": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPType(200), 40001, nil),
+		},
+		"destination unreachable of UDP": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypeDestinationUnreachable, 0, udpQuote),
+			want:    40000,
+		},
+		"packet too big of UDP": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypePacketTooBig, 0, udpQuote),
+			want:    40000,
+		},
+		"parameter problem of echo request": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypeParameterProblem, 0, echoQuote),
+			want:    40001,
+		},
+		"external interface down of traceroute request": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypeExternalInterfaceDown, 0, tracerouteQuote),
+			want:    40002,
+		},
+		"internal connectivity down of UDP": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypeInternalConnectivityDown, 0, udpQuote),
+			want:    40000,
+		},
+		"error of echo reply": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypeParameterProblem, 0, echoReplyQuote),
+		},
+		"error without quote": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypeDestinationUnreachable, 0, nil)[:7],
+		},
+		"error of truncated packet": {
+			proto:   slayers.L4SCMP,
+			payload: scmp(slayers.SCMPTypeDestinationUnreachable, 0, udpQuote[:20]),
+		},
+		"other protocol": {proto: slayers.L4TCP, payload: []byte{0x9c, 0x40, 0x1f, 0x90}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			port, ok := l4Port(tc.proto, tc.payload)
+			if ok != (tc.want != 0) || port != tc.want {
+				t.Errorf("l4Port() = %d %v, want %d", port, ok, tc.want)
+			}
+		})
+	}
+}